@@ -0,0 +1,132 @@
+package gonetlibs
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CaptiveState is the kind of network found by NetDetectCaptivePortal.
+type CaptiveState int
+
+const (
+	CaptiveOpen    CaptiveState = iota + 1 // the probe url answered as expected, the internet is reachable
+	CaptivePortal                          // something intercepted the probe: redirect, substituted page, 511
+	CaptiveOffline                         // the probe url could not be fetched
+)
+
+func (s CaptiveState) String() string {
+	switch s {
+	case CaptiveOpen:
+		return "open"
+	case CaptivePortal:
+		return "captive"
+	case CaptiveOffline:
+		return "offline"
+	}
+	return fmt.Sprintf("CaptiveState(%d)", int(s))
+}
+
+// CaptiveOptions tunes NetDetectCaptivePortal. A nil *CaptiveOptions uses the defaults.
+type CaptiveOptions struct {
+	URL     string        // plain http url answering a known response, default the Android generate_204 check
+	Status  int           // status URL answers, default 204
+	Body    string        // text the answer must contain, "" requires an empty body
+	Iface   string        // interface whose address is used as source, like ServerIsLive
+	Family  IPFamily      // any by default, IPv4 then IPv6
+	Timeout time.Duration // default 3s
+}
+
+// CaptiveResult is the classification of the network.
+type CaptiveResult struct {
+	State     CaptiveState
+	PortalURL string // login page of the portal when it could be found
+	Status    int    // status of the probe answer, 0 when there was none
+	Err       error  // why the network is offline
+}
+
+const defaultCaptiveURL = "http://connectivitycheck.gstatic.com/generate_204"
+
+// portals redirecting from an html page instead of a Location header
+var (
+	metaRefreshRe = regexp.MustCompile(`(?i)<meta[^>]+http-equiv=["']?refresh["']?[^>]*content=["']?\s*\d*\s*;?\s*url\s*=\s*([^"'>\s]+)`)
+	jsLocationRe  = regexp.MustCompile(`(?i)(?:window\.|document\.)?location(?:\.href)?\s*=\s*["']([^"']+)["']`)
+)
+
+/*
+NetDetectCaptivePortal fetches a url with a known answer, without following
+redirects, and tells whether the network is open, behind a captive portal or
+offline. Hotel and venue networks intercept plain http, so a working TCP
+connection (NetIsOnlineTcp) does not prove the internet is reachable.
+*/
+func NetDetectCaptivePortal(ctx context.Context, opts *CaptiveOptions) *CaptiveResult {
+	o := CaptiveOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if len(o.URL) == 0 {
+		o.URL = defaultCaptiveURL
+	}
+	if o.Status == 0 {
+		o.Status = http.StatusNoContent
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 3 * time.Second
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.URL, nil)
+	if err != nil {
+		return &CaptiveResult{State: CaptiveOffline, Err: err}
+	}
+	// some portals only intercept what looks like a browser
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Cache-Control", "no-cache")
+	resp, err := checkHTTPClient(o.Iface, o.Family, o.Timeout).Do(req)
+	if err != nil {
+		return &CaptiveResult{State: CaptiveOffline, Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return &CaptiveResult{State: CaptiveOffline, Status: resp.StatusCode, Err: err}
+	}
+	return classifyCaptive(resp, string(body), &o)
+}
+
+// classifyCaptive compares the answer to the expected one and looks for the portal url.
+func classifyCaptive(resp *http.Response, body string, o *CaptiveOptions) *CaptiveResult {
+	r := &CaptiveResult{State: CaptivePortal, Status: resp.StatusCode}
+	expectedBody := (len(o.Body) == 0 && len(strings.TrimSpace(body)) == 0) || (len(o.Body) != 0 && strings.Contains(body, o.Body))
+	if resp.StatusCode == o.Status && expectedBody {
+		r.State = CaptiveOpen
+		return r
+	}
+	if location := resp.Header.Get("Location"); len(location) != 0 && resp.StatusCode/100 == 3 {
+		r.PortalURL = resolveRef(resp.Request.URL, location)
+		return r
+	}
+	for _, re := range []*regexp.Regexp{metaRefreshRe, jsLocationRe} {
+		if m := re.FindStringSubmatch(body); m != nil {
+			r.PortalURL = resolveRef(resp.Request.URL, html.UnescapeString(m[1]))
+			return r
+		}
+	}
+	// a substituted page without link to the login page: the portal serves it at the probe url
+	r.PortalURL = resp.Request.URL.String()
+	return r
+}
+
+// resolveRef resolves a possibly relative reference against base.
+func resolveRef(base *url.URL, ref string) string {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
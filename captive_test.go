@@ -0,0 +1,63 @@
+package gonetlibs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNetDetectCaptivePortal(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/generate_204", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/hotspot-detect.html", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<HTML><HEAD><TITLE>Success</TITLE></HEAD><BODY>Success</BODY></HTML>")
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login?orig=1", http.StatusFound)
+	})
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0; url=http://10.0.0.1/portal?a=1&amp;b=2"></head></html>`)
+	})
+	mux.HandleFunc("/script", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<script>window.location.href = "https://wifi.example/auth";</script>`)
+	})
+	mux.HandleFunc("/auth-required", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNetworkAuthenticationRequired)
+		fmt.Fprint(w, "<html>Please log in</html>")
+	})
+	web := httptest.NewServer(mux)
+	defer web.Close()
+
+	closed, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + closed.Addr().String() + "/generate_204"
+	closed.Close()
+
+	tests := []struct {
+		opts   CaptiveOptions
+		state  CaptiveState
+		portal string
+	}{
+		{CaptiveOptions{URL: web.URL + "/generate_204"}, CaptiveOpen, ""},
+		{CaptiveOptions{URL: web.URL + "/hotspot-detect.html", Status: http.StatusOK, Body: "Success"}, CaptiveOpen, ""},
+		{CaptiveOptions{URL: web.URL + "/redirect"}, CaptivePortal, web.URL + "/login?orig=1"},
+		{CaptiveOptions{URL: web.URL + "/meta"}, CaptivePortal, "http://10.0.0.1/portal?a=1&b=2"},
+		{CaptiveOptions{URL: web.URL + "/script"}, CaptivePortal, "https://wifi.example/auth"},
+		{CaptiveOptions{URL: web.URL + "/auth-required"}, CaptivePortal, web.URL + "/auth-required"},
+		{CaptiveOptions{URL: web.URL + "/meta", Status: http.StatusOK, Body: "Success"}, CaptivePortal, "http://10.0.0.1/portal?a=1&b=2"},
+		{CaptiveOptions{URL: closedURL}, CaptiveOffline, ""},
+	}
+	for _, test := range tests {
+		r := NetDetectCaptivePortal(context.Background(), &test.opts)
+		if r.State != test.state || r.PortalURL != test.portal {
+			t.Errorf("%s: %s %q (status %d, %v), want %s %q", test.opts.URL, r.State, r.PortalURL, r.Status, r.Err, test.state, test.portal)
+		}
+	}
+}
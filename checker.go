@@ -0,0 +1,279 @@
+package gonetlibs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// CheckMethod is how a ConnectivityChecker probes one target.
+type CheckMethod int
+
+const (
+	CheckTCP  CheckMethod = iota // connect to host[:port], port 80 by default, like ServerIsLive
+	CheckICMP                    // echo request to host
+	CheckHTTP                    // GET a url and compare the status
+	CheckDNS                     // query a DNS server host[:port], port 53 by default
+)
+
+func (m CheckMethod) String() string {
+	switch m {
+	case CheckTCP:
+		return "tcp"
+	case CheckICMP:
+		return "icmp"
+	case CheckHTTP:
+		return "http"
+	case CheckDNS:
+		return "dns"
+	}
+	return fmt.Sprintf("CheckMethod(%d)", int(m))
+}
+
+// CheckTarget is one endpoint probed by a ConnectivityChecker.
+type CheckTarget struct {
+	Method  CheckMethod
+	Addr    string        // host[:port], or the url of CheckHTTP
+	Timeout time.Duration // 0 uses the checker Timeout
+	Status  int           // status expected from CheckHTTP, 0 accepts any 2xx
+	Query   string        // name asked to CheckDNS, default the root zone
+}
+
+func (t CheckTarget) String() string {
+	return t.Method.String() + ":" + t.Addr
+}
+
+// CheckResult is the answer of one target.
+type CheckResult struct {
+	Target CheckTarget
+	OK     bool
+	RTT    time.Duration
+	Err    error
+}
+
+// CheckReport is the outcome of ConnectivityChecker.Check.
+type CheckReport struct {
+	Online    bool // at least Quorum targets answered
+	Reachable int
+	RTT       time.Duration // fastest answer, 0 if none
+	Results   []CheckResult // in the order of the targets
+}
+
+/*
+ConnectivityChecker decides whether an interface is online by probing its own
+targets concurrently, each with its method and timeout. The check is online
+when Quorum targets answer.
+*/
+type ConnectivityChecker struct {
+	Targets []CheckTarget
+	Iface   string        // interface whose address is used as source, like ServerIsLive
+	Family  IPFamily      // IPv4 when unset like NetIsOnlineTcp
+	Timeout time.Duration // per target, default 500ms
+	Quorum  int           // targets that must answer, default 1
+}
+
+/*
+NewConnectivityChecker returns a checker of targets. Without targets it connects
+to the public DNS servers on port 80, like NetIsOnlineTcp.
+*/
+func NewConnectivityChecker(targets ...CheckTarget) *ConnectivityChecker {
+	if len(targets) == 0 {
+		for _, server := range dnslist {
+			targets = append(targets, CheckTarget{Method: CheckTCP, Addr: server})
+		}
+	}
+	return &ConnectivityChecker{Targets: targets, Family: IPFamilyV4, Timeout: time.Millisecond * 500, Quorum: 1}
+}
+
+// Check probes every target and waits for all the answers, or ctx.
+func (c *ConnectivityChecker) Check(ctx context.Context) *CheckReport {
+	return c.run(ctx, false)
+}
+
+// IsOnline probes every target and returns as soon as Quorum of them answered.
+func (c *ConnectivityChecker) IsOnline(ctx context.Context) bool {
+	return c.run(ctx, true).Online
+}
+
+func (c *ConnectivityChecker) run(ctx context.Context, stopAtQuorum bool) *CheckReport {
+	quorum := max(c.Quorum, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type indexed struct {
+		i int
+		r CheckResult
+	}
+	answers := make(chan indexed, len(c.Targets))
+	// the icmp targets share one engine, built before any probe runs
+	var engine *ICMPEngine
+	for _, t := range c.Targets {
+		if t.Method == CheckICMP {
+			engine = NewICMPEngine(&ICMPEngineOptions{Iface: c.Iface, Timeout: c.timeout(t)})
+			defer engine.Close()
+			break
+		}
+	}
+	for i, t := range c.Targets {
+		go func(i int, t CheckTarget, engine *ICMPEngine) {
+			answers <- indexed{i, c.probe(ctx, engine, t)}
+		}(i, t, engine)
+	}
+
+	report := &CheckReport{Results: make([]CheckResult, len(c.Targets))}
+	for i := range c.Targets {
+		report.Results[i] = CheckResult{Target: c.Targets[i], Err: context.Canceled}
+	}
+	for range c.Targets {
+		var a indexed
+		select {
+		case a = <-answers:
+		case <-ctx.Done():
+			return report
+		}
+		report.Results[a.i] = a.r
+		if a.r.OK {
+			report.Reachable++
+			if report.RTT == 0 || a.r.RTT < report.RTT {
+				report.RTT = a.r.RTT
+			}
+		}
+		report.Online = report.Reachable >= quorum
+		if report.Online && stopAtQuorum {
+			break
+		}
+	}
+	return report
+}
+
+func (c *ConnectivityChecker) timeout(t CheckTarget) time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return time.Millisecond * 500
+}
+
+func (c *ConnectivityChecker) family() IPFamily {
+	if c.Family == IPFamilyAny {
+		return IPFamilyV4
+	}
+	return c.Family
+}
+
+// probe runs the method of one target.
+func (c *ConnectivityChecker) probe(ctx context.Context, engine *ICMPEngine, t CheckTarget) CheckResult {
+	timeout := c.timeout(t)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	r := CheckResult{Target: t}
+	start := time.Now()
+	switch t.Method {
+	case CheckTCP:
+		var host, port string
+		if host, port, r.Err = serverHostPort(t.Addr); r.Err == nil {
+			var conn net.Conn
+			if conn, r.Err = dialHost(ctx, "tcp", host, port, c.family(), timeout, c.Iface); r.Err == nil {
+				conn.Close()
+			}
+		}
+	case CheckICMP:
+		var dst *net.IPAddr
+		if dst, r.Err = resolvePingTarget(t.Addr, c.family()); r.Err == nil {
+			results := make(chan EchoResult, 1)
+			if r.Err = engine.Send(dst, timeout, func(e EchoResult) { results <- e }); r.Err == nil {
+				e := <-results
+				r.Err = e.Err
+				r.OK, r.RTT = e.Err == nil, e.RTT
+				return r
+			}
+		}
+	case CheckHTTP:
+		r.Err = c.probeHTTP(ctx, t, timeout)
+	case CheckDNS:
+		r.Err = c.probeDNS(ctx, t, timeout)
+	default:
+		r.Err = fmt.Errorf("unknown check method %s", t.Method)
+	}
+	r.OK, r.RTT = r.Err == nil, time.Since(start)
+	return r
+}
+
+// checkHTTPClient returns an http client bound to iface that does not follow redirects.
+func checkHTTPClient(iface string, family IPFamily, timeout time.Duration) *http.Client {
+	transport := HttpClientNewDefaultTransPort()
+	transport.DisableKeepAlives = true
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		return dialHost(ctx, "tcp", host, port, family, timeout, iface)
+	}
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+func (c *ConnectivityChecker) probeHTTP(ctx context.Context, t CheckTarget, timeout time.Duration) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.Addr, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := checkHTTPClient(c.Iface, c.family(), timeout).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if (t.Status == 0 && resp.StatusCode/100 != 2) || (t.Status != 0 && resp.StatusCode != t.Status) {
+		return fmt.Errorf("%s answered %s", t.Addr, resp.Status)
+	}
+	return nil
+}
+
+func (c *ConnectivityChecker) probeDNS(ctx context.Context, t CheckTarget, timeout time.Duration) error {
+	host, port, err := net.SplitHostPort(t.Addr)
+	if err != nil {
+		host, port = t.Addr, "53"
+	}
+	conn, err := dialHost(ctx, "udp", host, port, c.family(), timeout, c.Iface)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	query := t.Query
+	if len(query) == 0 {
+		query = "."
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(query), dns.TypeNS)
+	co := &dns.Conn{Conn: conn}
+	if err = co.WriteMsg(m); err != nil {
+		return err
+	}
+	for {
+		reply, err := co.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if reply.Id != m.Id {
+			continue
+		}
+		// NXDOMAIN is an answer too, a server in trouble says SERVFAIL or REFUSED
+		if reply.Rcode != dns.RcodeSuccess && reply.Rcode != dns.RcodeNameError {
+			return fmt.Errorf("%s answered %s", t.Addr, dns.RcodeToString[reply.Rcode])
+		}
+		return nil
+	}
+}
@@ -0,0 +1,121 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestConnectivityChecker(t *testing.T) {
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/generate_204" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, "http://portal.example/login", http.StatusFound)
+	}))
+	defer web.Close()
+
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	closed, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	c := NewConnectivityChecker(
+		CheckTarget{Method: CheckHTTP, Addr: web.URL + "/generate_204", Status: http.StatusNoContent},
+		CheckTarget{Method: CheckHTTP, Addr: web.URL + "/redirected"},
+		CheckTarget{Method: CheckDNS, Addr: pc.LocalAddr().String()},
+		CheckTarget{Method: CheckTCP, Addr: web.Listener.Addr().String()},
+		CheckTarget{Method: CheckTCP, Addr: closedAddr},
+	)
+	c.Quorum = 3
+	report := c.Check(context.Background())
+	want := []bool{true, false, true, true, false}
+	for i, r := range report.Results {
+		if r.OK != want[i] {
+			t.Errorf("%s: ok %v, err %v", r.Target, r.OK, r.Err)
+		}
+	}
+	if !report.Online || report.Reachable != 3 {
+		t.Errorf("online %v with %d targets", report.Online, report.Reachable)
+	}
+
+	c.Quorum = 4
+	if c.IsOnline(context.Background()) {
+		t.Error("online without quorum")
+	}
+}
+
+func TestConnectivityCheckerCancel(t *testing.T) {
+	c := NewConnectivityChecker(CheckTarget{Method: CheckTCP, Addr: "192.0.2.1:80", Timeout: 10 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if c.IsOnline(ctx) || time.Since(start) > time.Second {
+		t.Errorf("cancelled check took %s", time.Since(start))
+	}
+}
+
+func TestConnectivityCheckerMixed(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// a tcp probe runs while the icmp engine of the later targets is set up
+	c := NewConnectivityChecker(
+		CheckTarget{Method: CheckTCP, Addr: l.Addr().String()},
+		CheckTarget{Method: CheckICMP, Addr: "127.0.0.1"},
+		CheckTarget{Method: CheckTCP, Addr: l.Addr().String()},
+		CheckTarget{Method: CheckICMP, Addr: "127.0.0.1"},
+	)
+	c.Timeout = time.Second
+	report := c.Check(context.Background())
+	for _, r := range report.Results {
+		switch {
+		case r.Target.Method == CheckICMP && errors.Is(r.Err, os.ErrPermission):
+		case !r.OK:
+			t.Errorf("%s: %v", r.Target, r.Err)
+		}
+	}
+}
+
+func TestConnectivityCheckerDefaultFamily(t *testing.T) {
+	if f := (&ConnectivityChecker{}).family(); f != IPFamilyV4 {
+		t.Errorf("family %v of a struct literal", f)
+	}
+	if f := (&ConnectivityChecker{Family: IPFamilyV6}).family(); f != IPFamilyV6 {
+		t.Errorf("family %v", f)
+	}
+}
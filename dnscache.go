@@ -0,0 +1,277 @@
+package gonetlibs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+/*
+DNSCache keeps the answers of a Resolver for their TTL. NXDOMAIN and NODATA
+answers are kept for the TTL of their SOA (RFC 2308), expired answers are
+served for StaleTTL more when the upstreams fail (RFC 8767), and an answer asked
+in the last tenth of its TTL is refreshed in the background.
+*/
+type DNSCache struct {
+	MaxEntries     int           // default 4096, the oldest entries are dropped beyond
+	MinTTL         time.Duration // answers are kept at least this long, default 0
+	MaxTTL         time.Duration // and at most this long, default 1 day
+	MaxNegativeTTL time.Duration // NXDOMAIN and NODATA are kept at most this long, default 15 minutes
+	StaleTTL       time.Duration // expired answers are served this long when the upstreams fail, default 1 day, <0 disables
+
+	mutex   sync.Mutex
+	entries map[dnsCacheKey]*dnsCacheEntry
+	calls   map[dnsCacheKey]*dnsCacheCall // upstream queries in flight
+	now     func() time.Time
+}
+
+type dnsCacheKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+type dnsCacheEntry struct {
+	reply      *dns.Msg
+	stored     time.Time
+	ttl        time.Duration
+	prefetched bool // a refresh is running or was done
+}
+
+// dnsCacheCall is an upstream query shared by the lookups missing the same answer.
+type dnsCacheCall struct {
+	done  chan struct{}
+	reply *dns.Msg
+	err   error
+}
+
+// staleAnswerTTL is the TTL of stale answers, so that clients ask again soon (RFC 8767 4).
+const staleAnswerTTL = 30
+
+// NewDNSCache returns an empty cache with the default limits.
+func NewDNSCache() *DNSCache {
+	return &DNSCache{MaxEntries: 4096, MaxTTL: 24 * time.Hour, MaxNegativeTTL: 15 * time.Minute, StaleTTL: 24 * time.Hour}
+}
+
+// Len returns the number of answers kept, expired ones included.
+func (c *DNSCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.entries)
+}
+
+// Flush forgets every answer.
+func (c *DNSCache) Flush() {
+	c.mutex.Lock()
+	c.entries = nil
+	c.mutex.Unlock()
+}
+
+func (c *DNSCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *DNSCache) staleTTL() time.Duration {
+	switch {
+	case c.StaleTTL < 0:
+		return 0
+	case c.StaleTTL == 0:
+		return 24 * time.Hour
+	}
+	return c.StaleTTL
+}
+
+/*
+exchange answers m from the cache, or through exchange when the answer is
+missing or expired. The answer of exchange is stored, and concurrent misses of
+the same question wait for the same exchange.
+*/
+func (c *DNSCache) exchange(ctx context.Context, m *dns.Msg, exchange func(context.Context, *dns.Msg) (*dns.Msg, error)) (*dns.Msg, error) {
+	if len(m.Question) != 1 {
+		return exchange(ctx, m)
+	}
+	q := m.Question[0]
+	key := dnsCacheKey{strings.ToLower(q.Name), q.Qtype, q.Qclass}
+
+	now := c.clock()
+	c.mutex.Lock()
+	e := c.entries[key]
+	var age time.Duration
+	if e != nil {
+		age = now.Sub(e.stored)
+	}
+	fresh := e != nil && age < e.ttl
+	prefetch := fresh && !e.prefetched && e.ttl-age < e.ttl/10
+	if prefetch {
+		e.prefetched = true
+	}
+	c.mutex.Unlock()
+
+	if fresh {
+		if prefetch {
+			c.call(ctx, key, m, exchange)
+		}
+		return cachedReply(e.reply, m.Id, age, 0), nil
+	}
+
+	call := c.call(ctx, key, m, exchange)
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, &NetError{Op: "lookup", Addr: questionName(m), Kind: ErrDNS, Err: ctx.Err()}
+	}
+	if call.err != nil {
+		// a lookup given up by its caller is not answered, even stale
+		if e != nil && age < e.ttl+c.staleTTL() && ctx.Err() == nil {
+			return cachedReply(e.reply, m.Id, age, staleAnswerTTL), nil
+		}
+		return nil, call.err
+	}
+	reply := call.reply.Copy()
+	reply.Id = m.Id
+	return reply, nil
+}
+
+// call starts the exchange of m, or returns the one in flight for key. exchange applies its own deadline.
+func (c *DNSCache) call(ctx context.Context, key dnsCacheKey, m *dns.Msg, exchange func(context.Context, *dns.Msg) (*dns.Msg, error)) *dnsCacheCall {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if call := c.calls[key]; call != nil {
+		return call
+	}
+	call := &dnsCacheCall{done: make(chan struct{})}
+	if c.calls == nil {
+		c.calls = make(map[dnsCacheKey]*dnsCacheCall)
+	}
+	c.calls[key] = call
+	m = m.Copy()
+	go func() {
+		// shared by several lookups, it does not stop with the one that started it
+		call.reply, call.err = exchange(context.WithoutCancel(ctx), m)
+		if call.err == nil {
+			c.store(key, call.reply)
+		}
+		c.mutex.Lock()
+		delete(c.calls, key)
+		c.mutex.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
+// store keeps reply under key when its TTL allows it.
+func (c *DNSCache) store(key dnsCacheKey, reply *dns.Msg) {
+	ttl, ok := c.ttlOf(reply)
+	if !ok {
+		return
+	}
+	now := c.clock()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[dnsCacheKey]*dnsCacheEntry)
+	}
+	maxEntries := c.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 4096
+	}
+	if _, replaced := c.entries[key]; !replaced && len(c.entries) >= maxEntries {
+		c.evict(now, maxEntries)
+	}
+	c.entries[key] = &dnsCacheEntry{reply: reply.Copy(), stored: now, ttl: ttl}
+}
+
+// evict drops the entries too old to be served stale, or the oldest one if there is none.
+func (c *DNSCache) evict(now time.Time, maxEntries int) {
+	var (
+		oldest    dnsCacheKey
+		oldestEnd time.Time
+	)
+	for key, e := range c.entries {
+		end := e.stored.Add(e.ttl + c.staleTTL())
+		if !end.After(now) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestEnd.IsZero() || end.Before(oldestEnd) {
+			oldest, oldestEnd = key, end
+		}
+	}
+	if len(c.entries) >= maxEntries {
+		delete(c.entries, oldest)
+	}
+}
+
+/*
+ttlOf tells how long reply may be kept: the lowest TTL of its answer, or for
+NXDOMAIN and NODATA the TTL of the SOA of the zone bounded by its minimum. A
+negative answer without SOA is not kept (RFC 2308 5).
+*/
+func (c *DNSCache) ttlOf(reply *dns.Msg) (time.Duration, bool) {
+	if reply.Truncated || (reply.Rcode != dns.RcodeSuccess && reply.Rcode != dns.RcodeNameError) {
+		return 0, false
+	}
+	maxTTL := c.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = 24 * time.Hour
+	}
+	negative := reply.Rcode == dns.RcodeNameError || len(reply.Answer) == 0
+	var (
+		ttl   uint32
+		found bool
+	)
+	if negative {
+		for _, rr := range reply.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ttl, found = min(soa.Hdr.Ttl, soa.Minttl), true
+				break
+			}
+		}
+		if c.MaxNegativeTTL > 0 {
+			maxTTL = min(maxTTL, c.MaxNegativeTTL)
+		} else {
+			maxTTL = min(maxTTL, 15*time.Minute)
+		}
+	} else {
+		for _, rr := range reply.Answer {
+			if !found || rr.Header().Ttl < ttl {
+				ttl, found = rr.Header().Ttl, true
+			}
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	return min(max(time.Duration(ttl)*time.Second, c.MinTTL), maxTTL), true
+}
+
+/*
+cachedReply copies a kept answer for the query id, its TTLs lowered by its age,
+or set to ttl when it is not 0.
+*/
+func cachedReply(kept *dns.Msg, id uint16, age time.Duration, ttl uint32) *dns.Msg {
+	reply := kept.Copy()
+	reply.Id = id
+	elapsed := uint32(age / time.Second)
+	for _, section := range [][]dns.RR{reply.Answer, reply.Ns, reply.Extra} {
+		for _, rr := range section {
+			h := rr.Header()
+			switch {
+			case h.Rrtype == dns.TypeOPT:
+			case ttl != 0:
+				h.Ttl = ttl
+			case h.Ttl > elapsed:
+				h.Ttl -= elapsed
+			default:
+				h.Ttl = 0
+			}
+		}
+	}
+	return reply
+}
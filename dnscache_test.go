@@ -0,0 +1,208 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// fakeUpstream answers A questions with a TTL of 100s, NXDOMAIN with an SOA for missing.test, and counts the queries.
+type fakeUpstream struct {
+	mutex   sync.Mutex
+	queries int
+	down    bool
+	last    byte
+	delay   time.Duration
+}
+
+func (f *fakeUpstream) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	f.mutex.Lock()
+	delay := f.delay
+	f.mutex.Unlock()
+	time.Sleep(delay)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.queries++
+	if f.down {
+		return nil, errors.New("upstream down")
+	}
+	f.last++
+	reply := new(dns.Msg)
+	reply.SetReply(m)
+	name := m.Question[0].Name
+	if name == "missing.test." {
+		reply.Rcode = dns.RcodeNameError
+		reply.Ns = append(reply.Ns, &dns.SOA{
+			Hdr: dns.RR_Header{Name: "test.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+			Ns:  "ns.test.", Mbox: "hostmaster.test.", Minttl: 60,
+		})
+		return reply, nil
+	}
+	reply.Answer = append(reply.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 100},
+		A:   net.IPv4(192, 0, 2, f.last),
+	})
+	return reply, nil
+}
+
+func (f *fakeUpstream) count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.queries
+}
+
+func TestDNSCache(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		now   = time.Unix(1700000000, 0)
+	)
+	advance := func(d time.Duration) {
+		mutex.Lock()
+		now = now.Add(d)
+		mutex.Unlock()
+	}
+	c := NewDNSCache()
+	c.now = func() time.Time {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return now
+	}
+	f := &fakeUpstream{}
+	ask := func(name string) (*dns.Msg, error) {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		reply, err := c.exchange(context.Background(), m, f.exchange)
+		if err == nil && reply.Id != m.Id {
+			t.Errorf("answer id %d for query %d", reply.Id, m.Id)
+		}
+		return reply, err
+	}
+
+	if _, err := ask("example.test."); err != nil {
+		t.Fatal(err)
+	}
+	advance(30 * time.Second)
+	reply, err := ask("EXAMPLE.test.")
+	if err != nil || f.count() != 1 {
+		t.Fatalf("%d queries, err %v", f.count(), err)
+	}
+	if ttl := reply.Answer[0].Header().Ttl; ttl != 70 {
+		t.Errorf("ttl %d after 30s", ttl)
+	}
+
+	// the last tenth of the TTL answers from the cache and refreshes in the background
+	advance(65 * time.Second)
+	if reply, err = ask("example.test."); err != nil || reply.Answer[0].(*dns.A).A.String() != "192.0.2.1" {
+		t.Fatalf("answer %v, err %v", reply, err)
+	}
+	refreshed := func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return !c.entries[dnsCacheKey{"example.test.", dns.TypeA, dns.ClassINET}].prefetched
+	}
+	for deadline := time.Now().Add(time.Second); !refreshed() && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	advance(10 * time.Second)
+	if reply, err = ask("example.test."); err != nil || reply.Answer[0].(*dns.A).A.String() != "192.0.2.2" || f.count() != 2 {
+		t.Fatalf("%d queries, answer %v, err %v", f.count(), reply, err)
+	}
+
+	// expired answers are served stale while the upstream is down
+	f.down = true
+	advance(200 * time.Second)
+	if reply, err = ask("example.test."); err != nil || reply.Answer[0].Header().Ttl != staleAnswerTTL {
+		t.Fatalf("stale answer %v, err %v", reply, err)
+	}
+	advance(25 * time.Hour)
+	if _, err = ask("example.test."); err == nil {
+		t.Error("answer older than StaleTTL served")
+	}
+	f.down = false
+
+	// NXDOMAIN is kept for the SOA minimum
+	if reply, err = ask("missing.test."); err != nil || reply.Rcode != dns.RcodeNameError {
+		t.Fatalf("answer %v, err %v", reply, err)
+	}
+	queries := f.count()
+	advance(59 * time.Second)
+	if _, err = ask("missing.test."); err != nil || f.count() != queries {
+		t.Errorf("%d queries, err %v", f.count()-queries, err)
+	}
+	advance(2 * time.Second)
+	if _, err = ask("missing.test."); err != nil || f.count() != queries+1 {
+		t.Errorf("%d queries, err %v", f.count()-queries, err)
+	}
+}
+
+func TestDNSCacheMaxEntries(t *testing.T) {
+	c := NewDNSCache()
+	c.MaxEntries = 2
+	f := &fakeUpstream{}
+	for _, name := range []string{"a.test.", "b.test.", "c.test."} {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		if _, err := c.exchange(context.Background(), m, f.exchange); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("%d entries", n)
+	}
+	c.Flush()
+	if n := c.Len(); n != 0 {
+		t.Errorf("%d entries after Flush", n)
+	}
+}
+
+func TestDNSCacheConcurrentMisses(t *testing.T) {
+	c := NewDNSCache()
+	f := &fakeUpstream{delay: 100 * time.Millisecond}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := new(dns.Msg)
+			m.SetQuestion("example.test.", dns.TypeA)
+			reply, err := c.exchange(context.Background(), m, f.exchange)
+			if err != nil || reply.Id != m.Id {
+				t.Errorf("answer %v, err %v", reply, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := f.count(); n != 1 {
+		t.Errorf("%d upstream queries for one question", n)
+	}
+}
+
+func TestDNSCacheCancelledNotStale(t *testing.T) {
+	c := NewDNSCache()
+	c.MaxTTL = time.Millisecond
+	f := &fakeUpstream{}
+	m := new(dns.Msg)
+	m.SetQuestion("example.test.", dns.TypeA)
+	if _, err := c.exchange(context.Background(), m, f.exchange); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	f.mutex.Lock()
+	f.down, f.delay = true, 50*time.Millisecond
+	f.mutex.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.exchange(ctx, m, f.exchange); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled lookup: err %v", err)
+	}
+	// the caller still waiting gets the stale answer
+	if reply, err := c.exchange(context.Background(), m, f.exchange); err != nil || reply.Answer[0].Header().Ttl != staleAnswerTTL {
+		t.Errorf("stale answer %v, err %v", reply, err)
+	}
+}
@@ -0,0 +1,189 @@
+package gonetlibs
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+/*
+Lookup returns the records of type qtype (dns.TypeMX, dns.TypeTXT...) of name,
+asked to the upstreams like LookupHost. A name without such records fails with
+a *NetError of kind ErrDNS wrapping a not found *net.DNSError.
+*/
+func (r *Resolver) Lookup(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.SetEdns0(1232, false)
+	reply, err := r.Exchange(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	var rrs []dns.RR
+	for _, rr := range reply.Answer {
+		// the answer may start with the CNAME chain leading to the records
+		if rr.Header().Rrtype == qtype || qtype == dns.TypeANY {
+			rrs = append(rrs, rr)
+		}
+	}
+	if len(rrs) == 0 {
+		return nil, errNoSuchHost(name)
+	}
+	return rrs, nil
+}
+
+// LookupMX returns the mail servers of name, by preference.
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*dns.MX, error) {
+	rrs, err := r.Lookup(ctx, name, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	mxs := recordsOf[*dns.MX](rrs)
+	sort.SliceStable(mxs, func(i, j int) bool { return mxs[i].Preference < mxs[j].Preference })
+	return mxs, nil
+}
+
+// LookupTXT returns the TXT records of name, the strings of each record joined.
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	rrs, err := r.Lookup(ctx, name, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txts []string
+	for _, txt := range recordsOf[*dns.TXT](rrs) {
+		txts = append(txts, strings.Join(txt.Txt, ""))
+	}
+	return txts, nil
+}
+
+/*
+LookupSRV returns the SRV records of _service._proto.name, or of name when
+service and proto are empty, in the order to try them: by priority, then
+randomly by weight (RFC 2782).
+*/
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) ([]*dns.SRV, error) {
+	target := name
+	if len(service) != 0 || len(proto) != 0 {
+		target = "_" + service + "._" + proto + "." + name
+	}
+	rrs, err := r.Lookup(ctx, target, dns.TypeSRV)
+	if err != nil {
+		return nil, err
+	}
+	srvs := recordsOf[*dns.SRV](rrs)
+	sortSRV(srvs)
+	return srvs, nil
+}
+
+// LookupCNAME returns the name name is an alias of, without the trailing dot.
+func (r *Resolver) LookupCNAME(ctx context.Context, name string) (string, error) {
+	rrs, err := r.Lookup(ctx, name, dns.TypeCNAME)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(rrs[0].(*dns.CNAME).Target, "."), nil
+}
+
+// LookupCAA returns the certification authorities allowed to issue certificates for name.
+func (r *Resolver) LookupCAA(ctx context.Context, name string) ([]*dns.CAA, error) {
+	rrs, err := r.Lookup(ctx, name, dns.TypeCAA)
+	if err != nil {
+		return nil, err
+	}
+	return recordsOf[*dns.CAA](rrs), nil
+}
+
+// LookupPTR returns the names the PTR records of name point to, without the trailing dot.
+func (r *Resolver) LookupPTR(ctx context.Context, name string) ([]string, error) {
+	rrs, err := r.Lookup(ctx, name, dns.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, ptr := range recordsOf[*dns.PTR](rrs) {
+		names = append(names, strings.TrimSuffix(ptr.Ptr, "."))
+	}
+	return names, nil
+}
+
+// LookupAddr returns the names of the IPv4 or IPv6 address ip, from its in-addr.arpa or ip6.arpa PTR records.
+func (r *Resolver) LookupAddr(ctx context.Context, ip string) ([]string, error) {
+	arpa, err := dns.ReverseAddr(ip)
+	if err != nil {
+		return nil, &NetError{Op: "lookup", Addr: ip, Kind: ErrDNS, Err: err}
+	}
+	return r.LookupPTR(ctx, arpa)
+}
+
+/* Records of type qtype of domain through DefaultResolver, see Resolver.Lookup */
+func ResolverDomainRecords(domain string, qtype uint16) ([]dns.RR, error) {
+	return ResolverDomainRecordsContext(context.Background(), domain, qtype)
+}
+
+func ResolverDomainRecordsContext(ctx context.Context, domain string, qtype uint16) ([]dns.RR, error) {
+	return DefaultResolver.Lookup(ctx, domain, qtype)
+}
+
+/* Names of an IPv4 or IPv6 address through DefaultResolver, see Resolver.LookupAddr */
+func ResolverIp2Domain(ip string) ([]string, error) {
+	return ResolverIp2DomainContext(context.Background(), ip)
+}
+
+func ResolverIp2DomainContext(ctx context.Context, ip string) ([]string, error) {
+	return DefaultResolver.LookupAddr(ctx, ip)
+}
+
+// recordsOf keeps the records of type T.
+func recordsOf[T dns.RR](rrs []dns.RR) []T {
+	var records []T
+	for _, rr := range rrs {
+		if record, ok := rr.(T); ok {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// sortSRV orders srvs by priority, each priority shuffled by weight like net.LookupSRV.
+func sortSRV(srvs []*dns.SRV) {
+	sort.SliceStable(srvs, func(i, j int) bool { return srvs[i].Priority < srvs[j].Priority })
+	for start := 0; start < len(srvs); {
+		end := start + 1
+		for end < len(srvs) && srvs[end].Priority == srvs[start].Priority {
+			end++
+		}
+		shuffleByWeight(srvs[start:end])
+		start = end
+	}
+}
+
+func shuffleByWeight(srvs []*dns.SRV) {
+	sum := 0
+	for _, srv := range srvs {
+		sum += int(srv.Weight)
+	}
+	for sum > 0 && len(srvs) > 1 {
+		s := 0
+		n := rand.Intn(sum)
+		for i := range srvs {
+			s += int(srvs[i].Weight)
+			if s > n {
+				if i > 0 {
+					srvs[0], srvs[i] = srvs[i], srvs[0]
+				}
+				break
+			}
+		}
+		sum -= int(srvs[0].Weight)
+		srvs = srvs[1:]
+	}
+}
+
+// errNoSuchHost is the error of a name without the records asked.
+func errNoSuchHost(name string) error {
+	return &NetError{Op: "lookup", Addr: name, Kind: ErrDNS, Err: &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}}
+}
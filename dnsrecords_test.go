@@ -0,0 +1,98 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestResolverRecords(t *testing.T) {
+	zone := map[uint16][]string{
+		dns.TypeMX: {
+			"example.test. 60 IN MX 20 backup.example.test.",
+			"example.test. 60 IN MX 10 mail.example.test.",
+		},
+		dns.TypeTXT:   {`example.test. 60 IN TXT "mode=" "gateway"`},
+		dns.TypeCAA:   {`example.test. 60 IN CAA 0 issue "letsencrypt.org"`},
+		dns.TypeCNAME: {"www.example.test. 60 IN CNAME example.test."},
+		dns.TypeSRV: {
+			"_mqtt._tcp.example.test. 60 IN SRV 20 0 1883 spare.example.test.",
+			"_mqtt._tcp.example.test. 60 IN SRV 10 5 1883 b.example.test.",
+			"_mqtt._tcp.example.test. 60 IN SRV 10 5 1883 a.example.test.",
+		},
+		dns.TypePTR: {"1.2.0.192.in-addr.arpa. 60 IN PTR host.example.test."},
+	}
+	addr := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		for _, s := range zone[q.Qtype] {
+			rr, err := dns.NewRR(s)
+			if err != nil {
+				t.Error(err)
+			}
+			if rr.Header().Name == q.Name {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		w.WriteMsg(m)
+	})
+	r, err := NewResolver(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mxs, err := r.LookupMX(ctx, "example.test")
+	if err != nil || len(mxs) != 2 || mxs[0].Mx != "mail.example.test." {
+		t.Errorf("mx %v, err %v", mxs, err)
+	}
+	txts, err := r.LookupTXT(ctx, "example.test")
+	if err != nil || !reflect.DeepEqual(txts, []string{"mode=gateway"}) {
+		t.Errorf("txt %q, err %v", txts, err)
+	}
+	caas, err := r.LookupCAA(ctx, "example.test")
+	if err != nil || len(caas) != 1 || caas[0].Value != "letsencrypt.org" {
+		t.Errorf("caa %v, err %v", caas, err)
+	}
+	cname, err := r.LookupCNAME(ctx, "www.example.test")
+	if err != nil || cname != "example.test" {
+		t.Errorf("cname %q, err %v", cname, err)
+	}
+	srvs, err := r.LookupSRV(ctx, "mqtt", "tcp", "example.test")
+	if err != nil || len(srvs) != 3 || srvs[0].Priority != 10 || srvs[1].Priority != 10 || srvs[2].Target != "spare.example.test." {
+		t.Errorf("srv %v, err %v", srvs, err)
+	}
+	names, err := r.LookupAddr(ctx, "192.0.2.1")
+	if err != nil || !reflect.DeepEqual(names, []string{"host.example.test"}) {
+		t.Errorf("ptr %q, err %v", names, err)
+	}
+
+	rrs, err := r.Lookup(ctx, "example.test", dns.TypeNAPTR)
+	var derr *net.DNSError
+	if len(rrs) != 0 || !errors.Is(err, ErrDNS) || !errors.As(err, &derr) || !derr.IsNotFound {
+		t.Errorf("naptr %v, err %v", rrs, err)
+	}
+	if _, err = r.LookupAddr(ctx, "not an ip"); !errors.Is(err, ErrDNS) {
+		t.Errorf("err %v", err)
+	}
+}
+
+func TestSortSRV(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		srvs := []*dns.SRV{
+			{Priority: 20, Weight: 0, Target: "c."},
+			{Priority: 10, Weight: 0, Target: "b."},
+			{Priority: 10, Weight: 100, Target: "a."},
+		}
+		sortSRV(srvs)
+		// a weight of 0 is only picked when no other weight is left
+		if srvs[0].Target != "a." || srvs[1].Target != "b." || srvs[2].Target != "c." {
+			t.Fatalf("order %v", srvs)
+		}
+	}
+}
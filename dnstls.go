@@ -0,0 +1,269 @@
+package gonetlibs
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dotIdleTimeout closes a DNS-over-TLS connection without query in flight, servers drop them anyway.
+const dotIdleTimeout = 10 * time.Second
+
+var errDotIdle = errors.New("dns over tls connection closed while idle")
+
+// SPKIPin returns the pin of cert for Upstream.SPKIPins: the base64 sha256 of its public key (RFC 7469).
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// tlsConfig returns the configuration of a tls upstream.
+func (u Upstream) tlsConfig() (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(u.Addr)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{ServerName: host, RootCAs: u.RootCAs, MinVersion: tls.VersionTLS12}
+	if len(u.ServerName) != 0 {
+		config.ServerName = u.ServerName
+	}
+	if len(u.SPKIPins) != 0 {
+		// the pins authenticate the server on their own (RFC 7858 4.2), whatever signed its certificate
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) != 0 {
+				pin := SPKIPin(cs.PeerCertificates[0])
+				for _, p := range u.SPKIPins {
+					if p == pin {
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("%s: certificate does not match the pinned keys", u)
+		}
+	}
+	return config, nil
+}
+
+/*
+exchangeTLS sends m to a DNS-over-TLS server (RFC 7858) on the connection kept
+for u. A kept connection the server closed in the meantime is dialed again once.
+*/
+func (r *Resolver) exchangeTLS(ctx context.Context, u Upstream, m *dns.Msg) (*dns.Msg, error) {
+	for retry := true; ; retry = false {
+		c, reused, err := r.dotConnTo(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		reply, err := c.exchange(ctx, m)
+		if err != nil && reused && retry && ctx.Err() == nil && c.broken() {
+			continue
+		}
+		return reply, err
+	}
+}
+
+// dotConnTo returns the connection to u, dialing it through r.dial when there is none. The queries made meanwhile wait for it.
+func (r *Resolver) dotConnTo(ctx context.Context, u Upstream) (c *dotConn, reused bool, err error) {
+	key := dotKey(u)
+	r.tlsMutex.Lock()
+	if c = r.tlsConns[key]; c == nil || c.broken() {
+		c = &dotConn{ready: make(chan struct{}), pending: make(map[uint16]chan *dns.Msg)}
+		if r.tlsConns == nil {
+			r.tlsConns = make(map[string]*dotConn)
+		}
+		r.tlsConns[key] = c
+		r.tlsMutex.Unlock()
+		return c, false, c.dial(ctx, r, u)
+	}
+	r.tlsMutex.Unlock()
+	select {
+	case <-c.ready:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	if c.conn == nil {
+		return nil, false, c.err
+	}
+	return c, true, nil
+}
+
+// dotKey tells the upstreams that may share a connection: same address, name and trust settings.
+func dotKey(u Upstream) string {
+	return fmt.Sprintf("%s %p %s", u, u.RootCAs, strings.Join(u.SPKIPins, ","))
+}
+
+// CloseIdleConnections closes the connections kept to the tls and https upstreams.
+func (r *Resolver) CloseIdleConnections() {
+	r.tlsMutex.Lock()
+	conns := r.tlsConns
+	r.tlsConns = nil
+	r.tlsMutex.Unlock()
+	for _, c := range conns {
+		select {
+		case <-c.ready:
+			if c.conn != nil {
+				c.closeIdle()
+			}
+		default:
+			// still dialing, its idle timer closes it
+		}
+	}
+	r.httpMutex.Lock()
+	if r.httpClient != nil {
+		r.httpClient.CloseIdleConnections()
+	}
+	r.httpMutex.Unlock()
+}
+
+// dotConn is a DNS-over-TLS connection shared by the queries to one upstream, answered in any order.
+type dotConn struct {
+	ready   chan struct{} // closed once dialed, conn is nil if that failed
+	conn    net.Conn
+	co      *dns.Conn
+	wmutex  sync.Mutex // one message written at a time
+	mutex   sync.Mutex
+	pending map[uint16]chan *dns.Msg
+	err     error // why the connection is unusable
+	idle    *time.Timer
+}
+
+func (c *dotConn) dial(ctx context.Context, r *Resolver, u Upstream) error {
+	defer close(c.ready)
+	config, err := u.tlsConfig()
+	if err == nil {
+		var conn net.Conn
+		if conn, err = r.dial(ctx, "tcp", u.Addr); err == nil {
+			tconn := tls.Client(conn, config)
+			if err = tconn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+			} else {
+				c.conn, c.co = tconn, &dns.Conn{Conn: tconn}
+			}
+		}
+	}
+	if err != nil {
+		c.mutex.Lock()
+		c.err = err
+		c.mutex.Unlock()
+		return err
+	}
+	c.idle = time.AfterFunc(dotIdleTimeout, c.closeIdle)
+	go c.read()
+	return nil
+}
+
+func (c *dotConn) broken() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err != nil
+}
+
+// exchange pipelines m with the other queries in flight, under an id unique on the connection.
+func (c *dotConn) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	q := m.Copy()
+	answer := make(chan *dns.Msg, 1)
+	c.mutex.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mutex.Unlock()
+		return nil, err
+	}
+	for c.pending[q.Id] != nil {
+		q.Id = dns.Id()
+	}
+	c.pending[q.Id] = answer
+	c.idle.Stop()
+	c.mutex.Unlock()
+	defer c.release(q.Id, answer)
+
+	c.wmutex.Lock()
+	deadline, _ := ctx.Deadline()
+	c.conn.SetWriteDeadline(deadline)
+	err := c.co.WriteMsg(q)
+	c.wmutex.Unlock()
+	if err != nil {
+		// a partly written message desynchronizes the stream
+		c.fail(err)
+		return nil, err
+	}
+	select {
+	case reply, ok := <-answer:
+		if !ok {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			return nil, c.err
+		}
+		reply.Id = m.Id
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+/*
+release forgets the query id and arms the idle timer once nothing is in flight.
+read already forgot the id of an answered query, which may be in use again.
+*/
+func (c *dotConn) release(id uint16, answer chan *dns.Msg) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.pending[id] == answer {
+		delete(c.pending, id)
+	}
+	if len(c.pending) == 0 && c.err == nil {
+		c.idle.Reset(dotIdleTimeout)
+	}
+}
+
+func (c *dotConn) read() {
+	for {
+		reply, err := c.co.ReadMsg()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		c.mutex.Lock()
+		answer := c.pending[reply.Id]
+		delete(c.pending, reply.Id)
+		c.mutex.Unlock()
+		if answer != nil {
+			answer <- reply
+		}
+	}
+}
+
+// closeIdle closes the connection unless queries are in flight.
+func (c *dotConn) closeIdle() {
+	c.mutex.Lock()
+	idle := len(c.pending) == 0
+	c.mutex.Unlock()
+	if idle {
+		c.fail(errDotIdle)
+	}
+}
+
+// fail closes the connection and wakes up the queries in flight.
+func (c *dotConn) fail(err error) {
+	c.mutex.Lock()
+	if c.err == nil {
+		c.err = err
+		for id, answer := range c.pending {
+			close(answer)
+			delete(c.pending, id)
+		}
+	}
+	c.mutex.Unlock()
+	c.idle.Stop()
+	c.conn.Close()
+}
@@ -0,0 +1,106 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Errors of the interface and connectivity helpers, check them with errors.Is.
+var (
+	ErrIfaceNotFound = errors.New("no such network interface")
+	ErrNoIPv4        = errors.New("no ipv4 address")
+	ErrNoIPv6        = errors.New("no ipv6 address")
+	ErrDNS           = errors.New("dns resolution failed")
+	ErrTimeout       = errors.New("timeout")
+	ErrPermission    = errors.New("permission denied")
+)
+
+// IfaceError tells why an interface can not be used, Err is ErrIfaceNotFound, ErrNoIPv4 or ErrNoIPv6.
+type IfaceError struct {
+	Iface  string
+	Err    error
+	NoAddr bool // the interface has no address at all
+}
+
+func (e *IfaceError) Error() string {
+	switch {
+	case e.Err == ErrIfaceNotFound:
+		return fmt.Sprintf("no such network interface %s", e.Iface)
+	case e.NoAddr:
+		return fmt.Sprintf("There isn't any ip on interface %s", e.Iface)
+	case e.Err == ErrNoIPv6:
+		return fmt.Sprintf("There isn't any ipv6 on interface %s", e.Iface)
+	}
+	return fmt.Sprintf("There isn't any ipv4 on interface %s", e.Iface)
+}
+
+func (e *IfaceError) Unwrap() error {
+	return e.Err
+}
+
+/*
+NetError is a failed lookup, dial or ping. Kind is ErrDNS or ErrPermission when
+the cause is known, errors.Is(err, ErrTimeout) holds for every kind of timeout.
+*/
+type NetError struct {
+	Op   string // lookup, dial, ping
+	Addr string
+	Kind error
+	Err  error
+}
+
+func (e *NetError) Error() string {
+	return e.Op + " " + e.Addr + ": " + e.Err.Error()
+}
+
+func (e *NetError) Unwrap() error {
+	return e.Err
+}
+
+func (e *NetError) Is(target error) bool {
+	if target == ErrTimeout {
+		return e.Timeout()
+	}
+	return e.Kind != nil && target == e.Kind
+}
+
+// Timeout tells whether a deadline expired, the one of a context included.
+func (e *NetError) Timeout() bool {
+	var ne net.Error
+	return errors.Is(e.Err, context.DeadlineExceeded) || errors.Is(e.Err, os.ErrDeadlineExceeded) ||
+		(errors.As(e.Err, &ne) && ne.Timeout())
+}
+
+// wrapNetError turns err into a *NetError telling its kind, typed errors are returned as is.
+func wrapNetError(op, addr string, err error) error {
+	var (
+		ierr *IfaceError
+		nerr *NetError
+		derr *net.DNSError
+	)
+	if err == nil || errors.As(err, &ierr) || errors.As(err, &nerr) {
+		return err
+	}
+	e := &NetError{Op: op, Addr: addr, Err: err}
+	switch {
+	case errors.As(err, &derr):
+		e.Kind = ErrDNS
+	case errors.Is(err, os.ErrPermission):
+		e.Kind = ErrPermission
+	}
+	return e
+}
+
+// ifaceByName is net.InterfaceByName failing with an *IfaceError when the interface does not exist.
+func ifaceByName(name string) (*net.Interface, error) {
+	ief, err := net.InterfaceByName(name)
+	// net does not export its "no such network interface" error
+	if err != nil && strings.Contains(err.Error(), ErrIfaceNotFound.Error()) {
+		return nil, &IfaceError{Iface: name, Err: ErrIfaceNotFound}
+	}
+	return ief, err
+}
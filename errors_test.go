@@ -0,0 +1,73 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIfaceErrors(t *testing.T) {
+	_, err := NetGetInterfaceIpv4Addr("no-such-iface0")
+	var ierr *IfaceError
+	if !errors.Is(err, ErrIfaceNotFound) || !errors.As(err, &ierr) || ierr.Iface != "no-such-iface0" {
+		t.Errorf("NetGetInterfaceIpv4Addr = %v", err)
+	}
+	for name, lookup := range map[string]func() error{
+		"NetGetIface":         func() error { _, err := NetGetIface("no-such-iface0"); return err },
+		"NetInterfaceDetails": func() error { _, err := NetInterfaceDetails("no-such-iface0"); return err },
+		"NetSweep":            func() error { _, err := NetSweepAll("no-such-iface0", nil); return err },
+	} {
+		if err := lookup(); !errors.Is(err, ErrIfaceNotFound) {
+			t.Errorf("%s = %v", name, err)
+		}
+	}
+	if _, err = NetGetInterfaceIpv6Addr("lo", Ip6ScopeGlobal); err != nil && !errors.Is(err, ErrNoIPv6) {
+		t.Errorf("NetGetInterfaceIpv6Addr = %v", err)
+	}
+	err = &IfaceError{Iface: "eth0", Err: ErrNoIPv4, NoAddr: true}
+	if err.Error() != "There isn't any ip on interface eth0" || !errors.Is(err, ErrNoIPv4) {
+		t.Errorf("%v", err)
+	}
+}
+
+func TestNetErrorKinds(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{&net.DNSError{Err: "no such host", Name: "x.invalid", IsNotFound: true}, ErrDNS},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, ErrTimeout},
+		{context.DeadlineExceeded, ErrTimeout},
+		{os.NewSyscallError("socket", os.ErrPermission), ErrPermission},
+	}
+	for _, test := range tests {
+		err := wrapNetError("dial", "192.0.2.1", test.err)
+		if !errors.Is(err, test.kind) || !errors.Is(err, test.err) {
+			t.Errorf("wrapNetError(%v) = %v, not %v", test.err, err, test.kind)
+		}
+	}
+	if errors.Is(wrapNetError("dial", "192.0.2.1", context.Canceled), ErrTimeout) {
+		t.Error("cancellation reported as a timeout")
+	}
+}
+
+func TestContextVariantsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if _, err := ResolverDomainContext(ctx, "example.invalid"); !errors.Is(err, ErrDNS) || !errors.Is(err, context.Canceled) {
+		t.Errorf("ResolverDomainContext = %v", err)
+	}
+	if NetIsOnlineTcpContext(ctx, 3, 1, IPFamilyV4) || NetIsOnlinePingContext(ctx, 3, 1, IPFamilyV4) {
+		t.Error("online with a cancelled context")
+	}
+	if err := NetCheckConectionToServerContext(ctx, "127.0.0.1:1", IPFamilyV4); err == nil {
+		t.Error("connected with a cancelled context")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("cancelled calls took %s", time.Since(start))
+	}
+}
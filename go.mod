@@ -5,19 +5,21 @@ go 1.22.0
 toolchain go1.23.0
 
 require (
+	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/lunny/log v0.0.0-20160921050905-7887c61bf0de
+	github.com/miekg/dns v1.1.62
 	github.com/sirupsen/logrus v1.9.3
 	github.com/sonnt85/mdns v0.0.0-20220514021123-7d4ceaeea2dd
 	golang.org/x/net v0.29.0
+	golang.org/x/sys v0.25.0
 )
 
 require (
 	github.com/fatih/color v1.17.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/miekg/dns v1.1.62 // indirect
 	github.com/sonnt85/gosutils/ppjson v0.0.0-20230927031609-2b3046a0b311 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
 	golang.org/x/mod v0.21.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
-	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/tools v0.25.0 // indirect
 )
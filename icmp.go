@@ -0,0 +1,306 @@
+package gonetlibs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ICMPMode selects the kind of socket used to send ICMP echo requests.
+type ICMPMode int
+
+const (
+	ICMPModeAuto     ICMPMode = iota // raw, then datagram when raw sockets are not permitted
+	ICMPModeRaw                      // SOCK_RAW, needs CAP_NET_RAW
+	ICMPModeDatagram                 // SOCK_DGRAM, allowed to the groups of net.ipv4.ping_group_range
+)
+
+func (m ICMPMode) String() string {
+	switch m {
+	case ICMPModeAuto:
+		return "auto"
+	case ICMPModeRaw:
+		return "raw"
+	case ICMPModeDatagram:
+		return "datagram"
+	}
+	return fmt.Sprintf("ICMPMode(%d)", int(m))
+}
+
+// icmpSocket is a socket sending ICMP or ICMPv6 echo requests and reading what comes back.
+type icmpSocket struct {
+	conn net.PacketConn
+	p4   *ipv4.PacketConn // nil on ICMPv6 sockets
+	p6   *ipv6.PacketConn // nil on ICMP sockets
+	mode ICMPMode         // raw or datagram, never auto
+	id   int              // echo identifier the kernel forces on datagram sockets, 0 on raw ones
+}
+
+/*
+listenICMP opens an ICMP socket (ICMPv6 if v6) bound to laddr ("" for any
+address). In auto mode a datagram socket is tried when the raw one can not be
+opened, so unprivileged users can ping.
+*/
+func listenICMP(mode ICMPMode, v6 bool, laddr string) (*icmpSocket, error) {
+	if len(laddr) == 0 {
+		laddr = "0.0.0.0"
+		if v6 {
+			laddr = "::"
+		}
+	}
+	switch mode {
+	case ICMPModeRaw:
+		return listenICMPRaw(v6, laddr)
+	case ICMPModeDatagram:
+		return listenICMPDatagram(v6, laddr)
+	}
+	s, err := listenICMPRaw(v6, laddr)
+	if err == nil {
+		return s, nil
+	}
+	if s, derr := listenICMPDatagram(v6, laddr); derr == nil {
+		return s, nil
+	}
+	return nil, err
+}
+
+func listenICMPRaw(v6 bool, laddr string) (*icmpSocket, error) {
+	network := "ip4:icmp"
+	if v6 {
+		network = "ip6:ipv6-icmp"
+	}
+	conn, err := net.ListenPacket(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	s := newICMPSocket(conn, v6, ICMPModeRaw)
+	if v6 {
+		// a raw ICMPv6 socket also gets all the neighbor discovery traffic
+		var f ipv6.ICMPFilter
+		f.SetAll(true)
+		for _, typ := range []ipv6.ICMPType{ipv6.ICMPTypeEchoReply, ipv6.ICMPTypeDestinationUnreachable,
+			ipv6.ICMPTypePacketTooBig, ipv6.ICMPTypeTimeExceeded, ipv6.ICMPTypeParameterProblem} {
+			f.Accept(typ)
+		}
+		s.p6.SetICMPFilter(&f)
+	}
+	return s, nil
+}
+
+func listenICMPDatagram(v6 bool, laddr string) (*icmpSocket, error) {
+	conn, err := listenICMPDgram(v6, laddr)
+	if err != nil {
+		return nil, err
+	}
+	s := newICMPSocket(conn, v6, ICMPModeDatagram)
+	// the kernel replaces the echo identifier by the local "port" of the socket
+	if uaddr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		s.id = uaddr.Port
+	}
+	return s, nil
+}
+
+func newICMPSocket(conn net.PacketConn, v6 bool, mode ICMPMode) *icmpSocket {
+	s := &icmpSocket{conn: conn, mode: mode}
+	// the TTL (hop limit) of replies is reported when the platform supports it
+	if v6 {
+		s.p6 = ipv6.NewPacketConn(conn)
+		s.p6.SetControlMessage(ipv6.FlagHopLimit, true)
+	} else {
+		s.p4 = ipv4.NewPacketConn(conn)
+		s.p4.SetControlMessage(ipv4.FlagTTL, true)
+	}
+	return s
+}
+
+func (s *icmpSocket) v6() bool {
+	return s.p6 != nil
+}
+
+func (s *icmpSocket) Close() error {
+	return s.conn.Close()
+}
+
+// SetTTL sets the TTL, or the hop limit on ICMPv6 sockets.
+func (s *icmpSocket) SetTTL(ttl int) error {
+	if s.v6() {
+		return s.p6.SetHopLimit(ttl)
+	}
+	return s.p4.SetTTL(ttl)
+}
+
+// SetTOS sets the TOS, or the traffic class on ICMPv6 sockets.
+func (s *icmpSocket) SetTOS(tos int) error {
+	if s.v6() {
+		return s.p6.SetTrafficClass(tos)
+	}
+	return s.p4.SetTOS(tos)
+}
+
+// SetDontFragment sets the DF bit on outgoing packets, or stops IPv6 fragmentation.
+func (s *icmpSocket) SetDontFragment(df bool) error {
+	return setDontFragment(s.conn, s.v6(), df)
+}
+
+func (s *icmpSocket) SetReadDeadline(t time.Time) error {
+	return s.conn.SetReadDeadline(t)
+}
+
+func (s *icmpSocket) WriteTo(b []byte, dst *net.IPAddr) (int, error) {
+	if s.mode == ICMPModeDatagram {
+		return s.conn.WriteTo(b, &net.UDPAddr{IP: dst.IP, Zone: dst.Zone})
+	}
+	return s.conn.WriteTo(b, dst)
+}
+
+// ReadFrom reads one ICMP message, ttl is -1 when it is not known.
+func (s *icmpSocket) ReadFrom(b []byte) (n, ttl int, peer net.IP, err error) {
+	var src net.Addr
+	ttl = -1
+	if s.v6() {
+		var cm *ipv6.ControlMessage
+		if n, cm, src, err = s.p6.ReadFrom(b); cm != nil {
+			ttl = cm.HopLimit
+		}
+	} else {
+		var cm *ipv4.ControlMessage
+		if n, cm, src, err = s.p4.ReadFrom(b); cm != nil {
+			ttl = cm.TTL
+		}
+	}
+	if err != nil {
+		return 0, -1, nil, err
+	}
+	switch addr := src.(type) {
+	case *net.IPAddr:
+		peer = addr.IP
+	case *net.UDPAddr:
+		peer = addr.IP
+	}
+	return n, ttl, peer, nil
+}
+
+// echoRequest marshals an echo request, ICMPv6 checksums are filled by the kernel.
+func (s *icmpSocket) echoRequest(id, seq int, data []byte) ([]byte, error) {
+	m := icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: seq, Data: data}}
+	if s.v6() {
+		m.Type = ipv6.ICMPTypeEchoRequest
+	}
+	return m.Marshal(nil)
+}
+
+// icmpEcho is the echo request or reply found in a received message.
+type icmpEcho struct {
+	ID, Seq int
+	Reply   bool      // echo reply, otherwise an error quoting our request
+	Type    icmp.Type // the type of the received message
+	Code    int
+	Data    []byte
+}
+
+/*
+parseICMPEcho decodes an echo reply, or an ICMP error (destination unreachable,
+time exceeded, parameter problem, packet too big) quoting one of our echo
+requests. v6 selects ICMPv6.
+*/
+func parseICMPEcho(b []byte, v6 bool) (*icmpEcho, error) {
+	proto, replyType := ProtocolICMP, icmp.Type(ipv4.ICMPTypeEchoReply)
+	requestType := byte(ipv4.ICMPTypeEcho)
+	if v6 {
+		proto, replyType = ProtocolIPv6ICMP, ipv6.ICMPTypeEchoReply
+		requestType = byte(ipv6.ICMPTypeEchoRequest)
+	}
+	m, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return nil, err
+	}
+	if body, ok := m.Body.(*icmp.Echo); ok {
+		if m.Type != replyType {
+			return nil, nil
+		}
+		return &icmpEcho{ID: body.ID, Seq: body.Seq, Reply: true, Type: m.Type, Data: body.Data}, nil
+	}
+	e := icmpErrorOf(m, b, v6)
+	if e == nil || e.Proto != proto || e.Inner[0] != requestType {
+		return nil, nil
+	}
+	return &icmpEcho{
+		ID:   int(binary.BigEndian.Uint16(e.Inner[4:6])),
+		Seq:  int(binary.BigEndian.Uint16(e.Inner[6:8])),
+		Type: e.Type,
+		Code: e.Code,
+	}, nil
+}
+
+// icmpError is an ICMP error message and the start of the packet it quotes.
+type icmpError struct {
+	Type  icmp.Type
+	Code  int
+	MTU   int    // next-hop MTU of "fragmentation needed" and "packet too big", 0 otherwise
+	Proto int    // protocol of the quoted packet
+	Src   net.IP // source of the quoted packet
+	Dst   net.IP // destination of the quoted packet
+	Inner []byte // first 8 bytes of the quoted transport header (ports, echo identifier...)
+}
+
+// parseICMPError decodes an ICMP error, nil if b is something else or does not quote enough.
+func parseICMPError(b []byte, v6 bool) (*icmpError, error) {
+	proto := ProtocolICMP
+	if v6 {
+		proto = ProtocolIPv6ICMP
+	}
+	m, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return nil, err
+	}
+	return icmpErrorOf(m, b, v6), nil
+}
+
+func icmpErrorOf(m *icmp.Message, b []byte, v6 bool) *icmpError {
+	e := &icmpError{Type: m.Type, Code: m.Code}
+	var quoted []byte
+	switch body := m.Body.(type) {
+	case *icmp.DstUnreach:
+		quoted = body.Data
+		// the next-hop MTU of "fragmentation needed" sits in the unused half of the header
+		if !v6 && m.Code == 4 && len(b) >= 8 {
+			e.MTU = int(binary.BigEndian.Uint16(b[6:8]))
+		}
+	case *icmp.TimeExceeded:
+		quoted = body.Data
+	case *icmp.ParamProb:
+		quoted = body.Data
+	case *icmp.PacketTooBig:
+		quoted = body.Data
+		e.MTU = body.MTU
+	default:
+		return nil
+	}
+	if v6 {
+		// the quoted IPv6 header is followed by our packet, we never send extension headers
+		if len(quoted) < ipv6.HeaderLen+8 {
+			return nil
+		}
+		e.Proto = int(quoted[6])
+		e.Src, e.Dst = net.IP(quoted[8:24]), net.IP(quoted[24:40])
+		e.Inner = quoted[ipv6.HeaderLen : ipv6.HeaderLen+8]
+		return e
+	}
+	// the error quotes the IPv4 header and the first 8 bytes of our packet
+	if len(quoted) < ipv4.HeaderLen {
+		return nil
+	}
+	hlen := int(quoted[0]&0x0f) << 2
+	if hlen < ipv4.HeaderLen || len(quoted) < hlen+8 {
+		return nil
+	}
+	e.Proto = int(quoted[9])
+	e.Src, e.Dst = net.IP(quoted[12:16]), net.IP(quoted[16:20])
+	e.Inner = quoted[hlen : hlen+8]
+	return e
+}
@@ -0,0 +1,113 @@
+package gonetlibs
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setDontFragment turns path MTU discovery to "do" on the socket, which sets DF on every packet.
+func setDontFragment(conn net.PacketConn, v6, df bool) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("can not set DF on %T", conn)
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		if v6 {
+			mode := unix.IPV6_PMTUDISC_DONT
+			dontfrag := 0
+			if df {
+				mode, dontfrag = unix.IPV6_PMTUDISC_DO, 1
+			}
+			if serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, mode); serr == nil {
+				serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_DONTFRAG, dontfrag)
+			}
+			return
+		}
+		mode := unix.IP_PMTUDISC_DONT
+		if df {
+			mode = unix.IP_PMTUDISC_DO
+		}
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, mode)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+/*
+setPMTUProbe sets DF on every packet like setDontFragment, but sends packets
+bigger than the path MTU cached by the kernel instead of failing them with
+EMSGSIZE, so a path MTU probe measures the path again.
+*/
+func setPMTUProbe(conn net.PacketConn, v6 bool) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("can not set DF on %T", conn)
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		if v6 {
+			if serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE); serr == nil {
+				serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_DONTFRAG, 1)
+			}
+			return
+		}
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// listenICMPDgram opens an unprivileged ICMP socket (SOCK_DGRAM, IPPROTO_ICMP or IPPROTO_ICMPV6).
+func listenICMPDgram(v6 bool, laddr string) (net.PacketConn, error) {
+	family, proto, network := unix.AF_INET, unix.IPPROTO_ICMP, "udp4"
+	if v6 {
+		family, proto, network = unix.AF_INET6, unix.IPPROTO_ICMPV6, "udp6"
+	}
+	addr, err := net.ResolveUDPAddr(network, net.JoinHostPort(laddr, "0"))
+	if err != nil {
+		return nil, err
+	}
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	var sa unix.Sockaddr
+	if v6 {
+		sa6 := &unix.SockaddrInet6{}
+		copy(sa6.Addr[:], addr.IP.To16())
+		if len(addr.Zone) != 0 {
+			if ief, err := net.InterfaceByName(addr.Zone); err == nil {
+				sa6.ZoneId = uint32(ief.Index)
+			}
+		}
+		sa = sa6
+	} else {
+		sa4 := &unix.SockaddrInet4{}
+		copy(sa4.Addr[:], addr.IP.To4())
+		sa = sa4
+	}
+	if err = unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	f := os.NewFile(uintptr(fd), "datagram-oriented icmp")
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
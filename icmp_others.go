@@ -0,0 +1,32 @@
+//go:build !linux
+
+package gonetlibs
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+
+	"golang.org/x/net/icmp"
+)
+
+// setDontFragment is only implemented on Linux.
+func setDontFragment(conn net.PacketConn, v6, df bool) error {
+	if !df {
+		return nil
+	}
+	return fmt.Errorf("setting DF is not supported on %s", runtime.GOOS)
+}
+
+// setPMTUProbe is setDontFragment where the kernel path MTU cache can not be bypassed.
+func setPMTUProbe(conn net.PacketConn, v6 bool) error {
+	return setDontFragment(conn, v6, true)
+}
+
+// listenICMPDgram opens an unprivileged ICMP socket where the platform has them.
+func listenICMPDgram(v6 bool, laddr string) (net.PacketConn, error) {
+	if v6 {
+		return icmp.ListenPacket("udp6", laddr)
+	}
+	return icmp.ListenPacket("udp4", laddr)
+}
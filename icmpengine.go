@@ -0,0 +1,332 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// EchoResult is the outcome of one echo request sent by an ICMPEngine.
+type EchoResult struct {
+	Addr *net.IPAddr
+	PingReply
+	Err error // nil for a reply, os.ErrDeadlineExceeded on timeout, or the ICMP error quoting the request
+}
+
+// ICMPEngineOptions tunes NewICMPEngine. A nil *ICMPEngineOptions uses the defaults.
+type ICMPEngineOptions struct {
+	Iface   string        // interface whose addresses are used as source, like Ping
+	Mode    ICMPMode      // socket kind, auto falls back to datagram when raw is not permitted
+	Size    int           // payload bytes, default 56
+	TTL     int           // 0 keeps the system default
+	Timeout time.Duration // default wait for a reply, 1s
+}
+
+/*
+ICMPEngine pings many targets through one ICMP socket per family, opened on
+first use. Replies are matched to their request by identifier and sequence
+number, so any number of targets can be probed concurrently. Callbacks run on
+the engine goroutines and should not block.
+*/
+type ICMPEngine struct {
+	opts    ICMPEngineOptions
+	mutex   sync.Mutex
+	sockets map[bool]*engineSocket // by v6
+	seq     int
+	closed  bool
+	payload []byte
+}
+
+// engineSocket is one socket of an engine and the requests waiting for its replies.
+type engineSocket struct {
+	s       *icmpSocket
+	id      int
+	pending map[int]*pendingEcho // by sequence number
+}
+
+type pendingEcho struct {
+	dst   *net.IPAddr
+	seq   int
+	sent  time.Time
+	timer *time.Timer
+	cb    func(EchoResult)
+}
+
+// NewICMPEngine returns an engine, its sockets are opened by the first probe of each family.
+func NewICMPEngine(opts *ICMPEngineOptions) *ICMPEngine {
+	o := ICMPEngineOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Size <= 0 {
+		o.Size = 56
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Second
+	}
+	payload := make([]byte, o.Size)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	return &ICMPEngine{opts: o, sockets: make(map[bool]*engineSocket), payload: payload}
+}
+
+/*
+Send sends one echo request to dst and calls cb exactly once: with the reply,
+with the ICMP error quoting the request, or with os.ErrDeadlineExceeded after
+timeout (the engine Timeout if 0). cb is not called when Send returns an error.
+*/
+func (e *ICMPEngine) Send(dst *net.IPAddr, timeout time.Duration, cb func(EchoResult)) error {
+	if timeout <= 0 {
+		timeout = e.opts.Timeout
+	}
+	// the zone of a link-local dst is filled in, not on the address of the caller
+	copied := *dst
+	dst = &copied
+	v6 := dst.IP.To4() == nil
+	e.mutex.Lock()
+	es, err := e.socket(v6, dst)
+	if err != nil {
+		e.mutex.Unlock()
+		return err
+	}
+	seq, ok := e.nextSeq(es)
+	if !ok {
+		e.mutex.Unlock()
+		return fmt.Errorf("too many echo requests in flight")
+	}
+	b, err := es.s.echoRequest(es.id, seq, e.payload)
+	if err != nil {
+		e.mutex.Unlock()
+		return err
+	}
+	p := &pendingEcho{dst: dst, seq: seq, cb: cb}
+	es.pending[seq] = p
+	p.sent = time.Now()
+	p.timer = time.AfterFunc(timeout, func() {
+		if e.take(es, seq, p) {
+			cb(EchoResult{Addr: dst, PingReply: PingReply{Seq: seq, TTL: -1}, Err: os.ErrDeadlineExceeded})
+		}
+	})
+	e.mutex.Unlock()
+
+	if _, err = es.s.WriteTo(b, dst); err != nil {
+		if e.take(es, seq, p) {
+			p.timer.Stop()
+		}
+		return err
+	}
+	return nil
+}
+
+/*
+Ping sends one echo request to addr (domain or IP) and waits for its reply,
+like the Ping function but through the engine sockets.
+*/
+func (e *ICMPEngine) Ping(ctx context.Context, addr string) (*net.IPAddr, time.Duration, error) {
+	dst, err := resolvePingTarget(addr, IPFamilyAny)
+	if err != nil {
+		return nil, 0, err
+	}
+	results := make(chan EchoResult, 1)
+	timeout := e.opts.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	if err = e.Send(dst, timeout, func(r EchoResult) { results <- r }); err != nil {
+		return dst, 0, err
+	}
+	select {
+	case r := <-results:
+		return dst, r.RTT, r.Err
+	case <-ctx.Done():
+		return dst, 0, ctx.Err()
+	}
+}
+
+/*
+Watch pings addr every interval until ctx is done and streams the results. The
+channel is closed once the last request got its result.
+*/
+func (e *ICMPEngine) Watch(ctx context.Context, addr string, interval time.Duration) (<-chan EchoResult, error) {
+	dst, err := resolvePingTarget(addr, IPFamilyAny)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	results := make(chan EchoResult, 4)
+	go func() {
+		var wg sync.WaitGroup
+		defer close(results)
+		defer wg.Wait()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			wg.Add(1)
+			err := e.Send(dst, min(e.opts.Timeout, interval), func(r EchoResult) {
+				defer wg.Done()
+				select {
+				case results <- r:
+				case <-ctx.Done():
+				}
+			})
+			if err != nil {
+				wg.Done()
+				select {
+				case results <- EchoResult{Addr: dst, PingReply: PingReply{TTL: -1}, Err: err}:
+				case <-ctx.Done():
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// Close closes the engine sockets, the requests in flight get net.ErrClosed.
+func (e *ICMPEngine) Close() error {
+	e.mutex.Lock()
+	e.closed = true
+	sockets := e.sockets
+	e.sockets = make(map[bool]*engineSocket)
+	e.mutex.Unlock()
+	for _, es := range sockets {
+		es.s.Close()
+	}
+	return nil
+}
+
+// socket returns the socket of a family, opening it on first use. Called with the mutex held.
+func (e *ICMPEngine) socket(v6 bool, dst *net.IPAddr) (*engineSocket, error) {
+	if e.closed {
+		return nil, net.ErrClosed
+	}
+	if es, ok := e.sockets[v6]; ok {
+		if dst.IP.IsLinkLocalUnicast() && len(dst.Zone) == 0 {
+			dst.Zone = e.opts.Iface
+		}
+		return es, nil
+	}
+	listenAddr, err := ifaceSourceAddr(e.opts.Iface, dst)
+	if err != nil {
+		return nil, err
+	}
+	s, err := listenICMP(e.opts.Mode, v6, listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	if e.opts.TTL > 0 {
+		if err = s.SetTTL(e.opts.TTL); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	es := &engineSocket{s: s, id: s.id, pending: make(map[int]*pendingEcho)}
+	if es.id == 0 {
+		es.id = int(atomic.AddUint32(&pingerID, 1) & 0xffff)
+	}
+	e.sockets[v6] = es
+	go e.read(es)
+	return es, nil
+}
+
+// nextSeq returns a sequence number without request in flight. Called with the mutex held.
+func (e *ICMPEngine) nextSeq(es *engineSocket) (int, bool) {
+	for range 0x10000 {
+		e.seq = (e.seq + 1) & 0xffff
+		if _, busy := es.pending[e.seq]; !busy {
+			return e.seq, true
+		}
+	}
+	return 0, false
+}
+
+// take removes the request seq if it is still p, and tells whether the caller owns its result.
+func (e *ICMPEngine) take(es *engineSocket, seq int, p *pendingEcho) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if es.pending[seq] != p {
+		return false
+	}
+	delete(es.pending, seq)
+	return true
+}
+
+/*
+read delivers the replies of a socket until it is closed or fails, a failed
+socket is dropped so that the next request opens a new one.
+*/
+func (e *ICMPEngine) read(es *engineSocket) {
+	buf := make([]byte, 65536)
+	v6 := es.s.v6()
+	var readErr error
+	for {
+		n, ttl, peer, err := es.s.ReadFrom(buf)
+		if err != nil {
+			// datagram sockets report the ICMP errors of a request as a failed read
+			if es.s.mode == ICMPModeDatagram && icmpInducedError(err) {
+				continue
+			}
+			readErr = err
+			break
+		}
+		now := time.Now()
+		echo, err := parseICMPEcho(buf[:n], v6)
+		if err != nil || echo == nil || echo.ID != es.id {
+			continue
+		}
+		e.mutex.Lock()
+		p, ok := es.pending[echo.Seq]
+		if !ok || (echo.Reply && !peer.Equal(p.dst.IP)) {
+			e.mutex.Unlock()
+			continue
+		}
+		delete(es.pending, echo.Seq)
+		e.mutex.Unlock()
+		p.timer.Stop()
+		r := EchoResult{Addr: p.dst, PingReply: PingReply{From: peer, Seq: echo.Seq, Size: len(echo.Data), TTL: ttl, RTT: now.Sub(p.sent)}}
+		if !echo.Reply {
+			r.Err = fmt.Errorf("got %v from %v; want echo reply", echo.Type, peer)
+		}
+		p.cb(r)
+	}
+
+	// the socket is closed or broken, fail what is still waiting on it
+	if errors.Is(readErr, net.ErrClosed) {
+		readErr = net.ErrClosed
+	}
+	e.mutex.Lock()
+	if e.sockets[v6] == es {
+		delete(e.sockets, v6)
+	}
+	pending := es.pending
+	es.pending = make(map[int]*pendingEcho)
+	e.mutex.Unlock()
+	es.s.Close()
+	for _, p := range pending {
+		p.timer.Stop()
+		p.cb(EchoResult{Addr: p.dst, PingReply: PingReply{Seq: p.seq, TTL: -1}, Err: readErr})
+	}
+}
+
+// icmpInducedError tells whether a read error comes from an ICMP error received by a datagram socket.
+func icmpInducedError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.ECONNREFUSED, syscall.EHOSTUNREACH, syscall.ENETUNREACH, syscall.EMSGSIZE} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestICMPEngineManyTargets(t *testing.T) {
+	e := NewICMPEngine(&ICMPEngineOptions{Timeout: 500 * time.Millisecond})
+	defer e.Close()
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		replies = make(map[string]int)
+	)
+	for i := 1; i <= 20; i++ {
+		dst := &net.IPAddr{IP: net.IPv4(127, 0, 0, byte(i))}
+		wg.Add(1)
+		err := e.Send(dst, 0, func(r EchoResult) {
+			defer wg.Done()
+			if r.Err != nil {
+				t.Errorf("%s: %v", r.Addr, r.Err)
+				return
+			}
+			mutex.Lock()
+			replies[r.From.String()]++
+			mutex.Unlock()
+		})
+		if errors.Is(err, os.ErrPermission) {
+			t.Skip("no permission to open an ICMP socket")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if len(replies) != 20 {
+		t.Errorf("replies from %v, want 127.0.0.1-20 once each", replies)
+	}
+}
+
+func TestICMPEngineWatch(t *testing.T) {
+	e := NewICMPEngine(nil)
+	defer e.Close()
+	if _, _, err := e.Ping(context.Background(), "127.0.0.1"); err != nil {
+		t.Skip(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 110*time.Millisecond)
+	defer cancel()
+	results, err := e.Watch(ctx, "127.0.0.1", 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for r := range results {
+		if r.Err != nil {
+			t.Errorf("seq %d: %v", r.Seq, r.Err)
+		}
+		n++
+	}
+	if n < 3 {
+		t.Errorf("%d results, want about 5", n)
+	}
+}
+
+func TestICMPEngineClose(t *testing.T) {
+	e := NewICMPEngine(nil)
+	e.Close()
+	if err := e.Send(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 0, func(EchoResult) {}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Send after Close = %v", err)
+	}
+}
+
+func TestICMPInducedError(t *testing.T) {
+	read := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "read", Net: "ip4:icmp", Err: os.NewSyscallError("recvfrom", errno)}
+	}
+	if !icmpInducedError(read(syscall.EHOSTUNREACH)) || !icmpInducedError(read(syscall.ECONNREFUSED)) {
+		t.Error("an ICMP error stops the reads")
+	}
+	if icmpInducedError(read(syscall.EBADF)) || icmpInducedError(net.ErrClosed) {
+		t.Error("a broken socket is read again")
+	}
+}
@@ -0,0 +1,96 @@
+package gonetlibs
+
+import (
+	"net"
+)
+
+// IfaceKind classifies a network interface by the kind of link behind it.
+type IfaceKind string
+
+const (
+	IfaceKindUnknown  IfaceKind = "unknown"
+	IfaceKindLoopback IfaceKind = "loopback"
+	IfaceKindEthernet IfaceKind = "ethernet"
+	IfaceKindWireless IfaceKind = "wireless"
+	IfaceKindBridge   IfaceKind = "bridge"
+	IfaceKindVeth     IfaceKind = "veth"
+	IfaceKindTun      IfaceKind = "tun"
+	IfaceKindDocker   IfaceKind = "docker"
+)
+
+// IfaceInfo describes one network interface.
+type IfaceInfo struct {
+	Index     int
+	Name      string
+	MAC       net.HardwareAddr
+	MTU       int
+	Flags     net.Flags
+	OperState string // RFC 2863 state as the kernel reports it: up, down, dormant, unknown...
+	Kind      IfaceKind
+}
+
+// IfaceFilter selects interfaces in NetListIfaces. A nil filter keeps every interface.
+type IfaceFilter struct {
+	Kinds        []IfaceKind // keep only these kinds, empty keeps every kind
+	ExcludeKinds []IfaceKind // drop these kinds
+	UpOnly       bool        // keep only interfaces that are administratively up
+}
+
+// RealIfaceFilter is the filter used by NetListAllRealIface when none is given:
+// everything except loopback and veth pairs.
+var RealIfaceFilter = &IfaceFilter{
+	ExcludeKinds: []IfaceKind{IfaceKindLoopback, IfaceKindVeth},
+}
+
+// Match reports whether the interface passes the filter.
+func (f *IfaceFilter) Match(info IfaceInfo) bool {
+	if f == nil {
+		return true
+	}
+	if f.UpOnly && info.Flags&net.FlagUp == 0 {
+		return false
+	}
+	for _, k := range f.ExcludeKinds {
+		if info.Kind == k {
+			return false
+		}
+	}
+	if len(f.Kinds) == 0 {
+		return true
+	}
+	for _, k := range f.Kinds {
+		if info.Kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// NetListIfaces lists the network interfaces of the host matching filter.
+func NetListIfaces(filter *IfaceFilter) ([]IfaceInfo, error) {
+	all, err := listIfaces()
+	if err != nil {
+		return nil, err
+	}
+	ifaces := make([]IfaceInfo, 0, len(all))
+	for _, info := range all {
+		if filter.Match(info) {
+			ifaces = append(ifaces, info)
+		}
+	}
+	return ifaces, nil
+}
+
+// NetGetIface returns the description of one interface.
+func NetGetIface(name string) (*IfaceInfo, error) {
+	all, err := listIfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range all {
+		if all[i].Name == name {
+			return &all[i], nil
+		}
+	}
+	return nil, &IfaceError{Iface: name, Err: ErrIfaceNotFound}
+}
@@ -0,0 +1,118 @@
+package gonetlibs
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var operStates = []string{"unknown", "notpresent", "down", "lowerlayerdown", "testing", "dormant", "up"}
+
+// listIfaces dumps the kernel link table over rtnetlink.
+func listIfaces() ([]IfaceInfo, error) {
+	msgs, err := nlDump(unix.RTM_GETLINK, unix.AF_UNSPEC, unix.SizeofIfInfomsg)
+	if err != nil {
+		return nil, err
+	}
+	ifaces := make([]IfaceInfo, 0, len(msgs))
+	for _, m := range msgs {
+		if info, ok := parseLinkMessage(m); ok {
+			ifaces = append(ifaces, info)
+		}
+	}
+	return ifaces, nil
+}
+
+// parseLinkMessage decodes a RTM_NEWLINK/RTM_DELLINK message.
+func parseLinkMessage(m syscall.NetlinkMessage) (info IfaceInfo, ok bool) {
+	if m.Header.Type != unix.RTM_NEWLINK && m.Header.Type != unix.RTM_DELLINK {
+		return info, false
+	}
+	if len(m.Data) < unix.SizeofIfInfomsg {
+		return info, false
+	}
+	arphrd := binary.NativeEndian.Uint16(m.Data[2:4])
+	info.Index = int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+	info.Flags = linkFlags(binary.NativeEndian.Uint32(m.Data[8:12]))
+	info.OperState = operStates[0]
+
+	linkKind := ""
+	for _, a := range nlParseAttrs(m.Data[unix.SizeofIfInfomsg:]) {
+		switch a.Type {
+		case unix.IFLA_IFNAME:
+			info.Name = nlString(a.Value)
+		case unix.IFLA_ADDRESS:
+			if len(a.Value) != 0 {
+				info.MAC = net.HardwareAddr(append([]byte(nil), a.Value...))
+			}
+		case unix.IFLA_MTU:
+			info.MTU = int(nlUint32(a.Value))
+		case unix.IFLA_OPERSTATE:
+			if len(a.Value) != 0 && int(a.Value[0]) < len(operStates) {
+				info.OperState = operStates[a.Value[0]]
+			}
+		case unix.IFLA_LINKINFO:
+			for _, li := range nlParseAttrs(a.Value) {
+				if li.Type == unix.IFLA_INFO_KIND {
+					linkKind = nlString(li.Value)
+				}
+			}
+		}
+	}
+	info.Kind = linkKindOf(info.Name, arphrd, linkKind)
+	return info, true
+}
+
+func linkFlags(rawFlags uint32) net.Flags {
+	var f net.Flags
+	if rawFlags&unix.IFF_UP != 0 {
+		f |= net.FlagUp
+	}
+	if rawFlags&unix.IFF_RUNNING != 0 {
+		f |= net.FlagRunning
+	}
+	if rawFlags&unix.IFF_BROADCAST != 0 {
+		f |= net.FlagBroadcast
+	}
+	if rawFlags&unix.IFF_LOOPBACK != 0 {
+		f |= net.FlagLoopback
+	}
+	if rawFlags&unix.IFF_POINTOPOINT != 0 {
+		f |= net.FlagPointToPoint
+	}
+	if rawFlags&unix.IFF_MULTICAST != 0 {
+		f |= net.FlagMulticast
+	}
+	return f
+}
+
+// linkKindOf classifies a link from its hardware type, its rtnetlink kind
+// (bridge, veth, tun...) and sysfs for wireless devices.
+func linkKindOf(name string, arphrd uint16, linkKind string) IfaceKind {
+	switch {
+	case arphrd == unix.ARPHRD_LOOPBACK:
+		return IfaceKindLoopback
+	case linkKind == "veth":
+		return IfaceKindVeth
+	case linkKind == "bridge":
+		if name == "docker0" || strings.HasPrefix(name, "br-") {
+			return IfaceKindDocker
+		}
+		return IfaceKindBridge
+	case linkKind == "tun", arphrd == unix.ARPHRD_NONE, arphrd == unix.ARPHRD_PPP:
+		return IfaceKindTun
+	case arphrd == unix.ARPHRD_ETHER:
+		if _, err := os.Stat("/sys/class/net/" + name + "/wireless"); err == nil {
+			return IfaceKindWireless
+		}
+		if _, err := os.Stat("/sys/class/net/" + name + "/phy80211"); err == nil {
+			return IfaceKindWireless
+		}
+		return IfaceKindEthernet
+	}
+	return IfaceKindUnknown
+}
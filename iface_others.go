@@ -0,0 +1,48 @@
+//go:build !linux
+
+package gonetlibs
+
+import (
+	"net"
+	"strings"
+)
+
+// listIfaces falls back to net.Interfaces where rtnetlink is not available,
+// the kind is guessed from the flags and the interface name.
+func listIfaces() ([]IfaceInfo, error) {
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	ifaces := make([]IfaceInfo, 0, len(all))
+	for _, ief := range all {
+		info := IfaceInfo{
+			Index:     ief.Index,
+			Name:      ief.Name,
+			MAC:       ief.HardwareAddr,
+			MTU:       ief.MTU,
+			Flags:     ief.Flags,
+			OperState: "down",
+			Kind:      IfaceKindEthernet,
+		}
+		if ief.Flags&net.FlagRunning != 0 {
+			info.OperState = "up"
+		}
+		switch {
+		case ief.Flags&net.FlagLoopback != 0:
+			info.Kind = IfaceKindLoopback
+		case strings.HasPrefix(ief.Name, "veth"):
+			info.Kind = IfaceKindVeth
+		case strings.HasPrefix(ief.Name, "docker"), strings.HasPrefix(ief.Name, "br-"):
+			info.Kind = IfaceKindDocker
+		case strings.HasPrefix(ief.Name, "bridge"):
+			info.Kind = IfaceKindBridge
+		case ief.Flags&net.FlagPointToPoint != 0, strings.HasPrefix(ief.Name, "tun"), strings.HasPrefix(ief.Name, "utun"):
+			info.Kind = IfaceKindTun
+		case strings.HasPrefix(ief.Name, "wl"):
+			info.Kind = IfaceKindWireless
+		}
+		ifaces = append(ifaces, info)
+	}
+	return ifaces, nil
+}
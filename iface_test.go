@@ -0,0 +1,49 @@
+package gonetlibs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIfaceFilterMatch(t *testing.T) {
+	eth := IfaceInfo{Name: "eth0", Kind: IfaceKindEthernet, Flags: net.FlagUp}
+	lo := IfaceInfo{Name: "lo", Kind: IfaceKindLoopback, Flags: net.FlagUp | net.FlagLoopback}
+	veth := IfaceInfo{Name: "veth12ab", Kind: IfaceKindVeth}
+
+	tests := []struct {
+		name   string
+		filter *IfaceFilter
+		info   IfaceInfo
+		want   bool
+	}{
+		{"nil filter", nil, veth, true},
+		{"real keeps ethernet", RealIfaceFilter, eth, true},
+		{"real drops loopback", RealIfaceFilter, lo, false},
+		{"real drops veth", RealIfaceFilter, veth, false},
+		{"kinds", &IfaceFilter{Kinds: []IfaceKind{IfaceKindWireless}}, eth, false},
+		{"up only", &IfaceFilter{UpOnly: true}, veth, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.info); got != tt.want {
+			t.Errorf("%s: Match(%s) = %v, want %v", tt.name, tt.info.Name, got, tt.want)
+		}
+	}
+}
+
+func TestNetListIfaces(t *testing.T) {
+	ifaces, err := NetListIfaces(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range ifaces {
+		if iface.Index <= 0 || len(iface.Name) == 0 {
+			t.Errorf("bad interface %+v", iface)
+		}
+		t.Logf("%+v", iface)
+	}
+	names, err := NetListAllRealIface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("real interfaces: %v", names)
+}
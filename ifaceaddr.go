@@ -0,0 +1,140 @@
+package gonetlibs
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+)
+
+// IfaceAddr is one address configured on an interface.
+type IfaceAddr struct {
+	IP        net.IP
+	Mask      net.IPMask
+	PrefixLen int
+	Family    int    // 4 or 6
+	Scope     string // global, site, link or host
+	Secondary bool   // IPv4 secondary address, or IPv6 temporary (privacy) address
+	Broadcast net.IP // IPv4 broadcast address, nil if none
+	Label     string
+}
+
+// CIDR returns the address in a.b.c.d/n form, as `ip addr` prints it.
+func (a IfaceAddr) CIDR() string {
+	return a.IP.String() + "/" + strconv.Itoa(a.PrefixLen)
+}
+
+// Prefix returns the address with its prefix length as a netip.Prefix, see package ipcalc.
+func (a IfaceAddr) Prefix() netip.Prefix {
+	addr, _ := netip.AddrFromSlice(a.IP)
+	return netip.PrefixFrom(addr.Unmap(), a.PrefixLen)
+}
+
+// Network returns the network the address belongs to.
+func (a IfaceAddr) Network() *net.IPNet {
+	return &net.IPNet{IP: a.IP.Mask(a.Mask), Mask: a.Mask}
+}
+
+// IfaceDetails is an interface with every address configured on it,
+// primary addresses come before secondary ones.
+type IfaceDetails struct {
+	IfaceInfo
+	Addrs []IfaceAddr
+}
+
+// Field returns one field of the interface selected by the Iface* constants
+// (IfaceIname, IfaceMacddr, IfaceCidr, IfaceIp4, IfaceIp6, IfaceMask).
+// Address fields return one entry per address.
+func (d *IfaceDetails) Field(field int) []string {
+	values := make([]string, 0)
+	switch field {
+	case IfaceIname:
+		values = append(values, d.Name)
+	case IfaceMacddr:
+		if len(d.MAC) != 0 {
+			values = append(values, d.MAC.String())
+		}
+	case IfaceCidr:
+		for _, a := range d.Addrs {
+			values = append(values, a.CIDR())
+		}
+	case IfaceIp4:
+		for _, a := range d.Addrs {
+			if a.Family == 4 {
+				values = append(values, a.IP.String())
+			}
+		}
+	case IfaceIp6:
+		for _, a := range d.Addrs {
+			if a.Family == 6 {
+				values = append(values, a.IP.String())
+			}
+		}
+	case IfaceMask:
+		for _, a := range d.Addrs {
+			values = append(values, net.IP(a.Mask).String())
+		}
+	}
+	return values
+}
+
+// NetInterfaceDetails returns an interface with all of its addresses.
+func NetInterfaceDetails(name string) (*IfaceDetails, error) {
+	all, err := NetAllInterfaceDetails()
+	if err != nil {
+		return nil, err
+	}
+	for i := range all {
+		if all[i].Name == name {
+			return &all[i], nil
+		}
+	}
+	return nil, &IfaceError{Iface: name, Err: ErrIfaceNotFound}
+}
+
+// NetAllInterfaceDetails returns every interface of the host with its addresses.
+func NetAllInterfaceDetails() ([]IfaceDetails, error) {
+	ifaces, err := listIfaces()
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := listIfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	details := make([]IfaceDetails, 0, len(ifaces))
+	for _, info := range ifaces {
+		d := IfaceDetails{IfaceInfo: info}
+		for _, a := range addrs[info.Index] {
+			if !a.Secondary {
+				d.Addrs = append(d.Addrs, a)
+			}
+		}
+		for _, a := range addrs[info.Index] {
+			if a.Secondary {
+				d.Addrs = append(d.Addrs, a)
+			}
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+// NetGetInterfaceField returns one field of an interface, see IfaceDetails.Field.
+func NetGetInterfaceField(name string, field int) ([]string, error) {
+	d, err := NetInterfaceDetails(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.Field(field), nil
+}
+
+// ipScope guesses the scope of an address when the kernel does not report it.
+func ipScope(ip net.IP) string {
+	switch {
+	case ip.IsLoopback():
+		return "host"
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return "link"
+	}
+	return "global"
+}
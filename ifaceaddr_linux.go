@@ -0,0 +1,89 @@
+package gonetlibs
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// listIfaceAddrs dumps the kernel address table, keyed by interface index.
+func listIfaceAddrs() (map[int][]IfaceAddr, error) {
+	msgs, err := nlDump(unix.RTM_GETADDR, unix.AF_UNSPEC, unix.SizeofIfAddrmsg)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make(map[int][]IfaceAddr)
+	for _, m := range msgs {
+		if index, a, ok := parseAddrMessage(m); ok {
+			addrs[index] = append(addrs[index], a)
+		}
+	}
+	return addrs, nil
+}
+
+// parseAddrMessage decodes a RTM_NEWADDR/RTM_DELADDR message.
+func parseAddrMessage(m syscall.NetlinkMessage) (index int, a IfaceAddr, ok bool) {
+	if m.Header.Type != unix.RTM_NEWADDR && m.Header.Type != unix.RTM_DELADDR {
+		return 0, a, false
+	}
+	if len(m.Data) < unix.SizeofIfAddrmsg {
+		return 0, a, false
+	}
+	family := m.Data[0]
+	a.PrefixLen = int(m.Data[1])
+	flags := uint32(m.Data[2])
+	a.Scope = scopeName(m.Data[3])
+	index = int(binary.NativeEndian.Uint32(m.Data[4:8]))
+
+	bits := 8 * net.IPv4len
+	a.Family = 4
+	if family == unix.AF_INET6 {
+		bits = 8 * net.IPv6len
+		a.Family = 6
+	} else if family != unix.AF_INET {
+		return 0, a, false
+	}
+	a.Mask = net.CIDRMask(a.PrefixLen, bits)
+
+	var address, local net.IP
+	for _, attr := range nlParseAttrs(m.Data[unix.SizeofIfAddrmsg:]) {
+		switch attr.Type {
+		case unix.IFA_ADDRESS:
+			address = append(net.IP(nil), attr.Value...)
+		case unix.IFA_LOCAL:
+			local = append(net.IP(nil), attr.Value...)
+		case unix.IFA_BROADCAST:
+			a.Broadcast = append(net.IP(nil), attr.Value...)
+		case unix.IFA_LABEL:
+			a.Label = nlString(attr.Value)
+		case unix.IFA_FLAGS:
+			flags = nlUint32(attr.Value)
+		}
+	}
+	// On point-to-point links IFA_ADDRESS is the peer, IFA_LOCAL is ours.
+	a.IP = address
+	if local != nil {
+		a.IP = local
+	}
+	if a.IP == nil {
+		return 0, a, false
+	}
+	a.Secondary = flags&unix.IFA_F_SECONDARY != 0
+	return index, a, true
+}
+
+func scopeName(scope uint8) string {
+	switch scope {
+	case unix.RT_SCOPE_UNIVERSE:
+		return "global"
+	case unix.RT_SCOPE_SITE:
+		return "site"
+	case unix.RT_SCOPE_LINK:
+		return "link"
+	case unix.RT_SCOPE_HOST:
+		return "host"
+	}
+	return "nowhere"
+}
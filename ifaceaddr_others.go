@@ -0,0 +1,45 @@
+//go:build !linux
+
+package gonetlibs
+
+import (
+	"net"
+)
+
+// listIfaceAddrs reads the addresses through net.Interface.Addrs, keyed by
+// interface index. Secondary status is not available here.
+func listIfaceAddrs() (map[int][]IfaceAddr, error) {
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	addrs := make(map[int][]IfaceAddr)
+	for _, ief := range all {
+		ifaddrs, err := ief.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, ifaddr := range ifaddrs {
+			ipnet, ok := ifaddr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			a := IfaceAddr{IP: ipnet.IP, Mask: ipnet.Mask, Family: 6, Scope: ipScope(ipnet.IP)}
+			a.PrefixLen, _ = ipnet.Mask.Size()
+			if ip4 := ipnet.IP.To4(); ip4 != nil {
+				a.IP, a.Family = ip4, 4
+				if len(ipnet.Mask) == net.IPv6len {
+					a.Mask = ipnet.Mask[12:]
+				}
+				if ief.Flags&net.FlagBroadcast != 0 {
+					a.Broadcast = make(net.IP, net.IPv4len)
+					for i := range ip4 {
+						a.Broadcast[i] = ip4[i] | ^a.Mask[i]
+					}
+				}
+			}
+			addrs[ief.Index] = append(addrs[ief.Index], a)
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,49 @@
+package gonetlibs
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestIfaceDetailsField(t *testing.T) {
+	d := &IfaceDetails{
+		IfaceInfo: IfaceInfo{Name: "eth0", MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}},
+		Addrs: []IfaceAddr{
+			{IP: net.ParseIP("192.168.1.10").To4(), Mask: net.CIDRMask(24, 32), PrefixLen: 24, Family: 4},
+			{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128), PrefixLen: 64, Family: 6},
+			{IP: net.ParseIP("10.0.0.2").To4(), Mask: net.CIDRMask(8, 32), PrefixLen: 8, Family: 4, Secondary: true},
+		},
+	}
+	tests := []struct {
+		field int
+		want  []string
+	}{
+		{IfaceIname, []string{"eth0"}},
+		{IfaceMacddr, []string{"02:00:00:00:00:01"}},
+		{IfaceCidr, []string{"192.168.1.10/24", "fe80::1/64", "10.0.0.2/8"}},
+		{IfaceIp4, []string{"192.168.1.10", "10.0.0.2"}},
+		{IfaceIp6, []string{"fe80::1"}},
+		{IfaceMask, []string{"255.255.255.0", "ffff:ffff:ffff:ffff::", "255.0.0.0"}},
+	}
+	for _, tt := range tests {
+		if got := d.Field(tt.field); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Field(%d) = %v, want %v", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestNetAllInterfaceDetails(t *testing.T) {
+	all, err := NetAllInterfaceDetails()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range all {
+		t.Logf("%s %s cidr=%v", d.Name, d.Kind, d.Field(IfaceCidr))
+		for _, a := range d.Addrs {
+			if a.Network() == nil || (a.Family != 4 && a.Family != 6) {
+				t.Errorf("bad address %+v on %s", a, d.Name)
+			}
+		}
+	}
+}
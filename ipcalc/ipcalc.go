@@ -0,0 +1,303 @@
+// Package ipcalc does prefix arithmetic on netip.Prefix and net.IPNet:
+// containment, host enumeration, splitting, summarization and address allocation.
+package ipcalc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxList is the largest number of entries Hosts and Split agree to return.
+const MaxList = 1 << maxListBits
+
+const maxListBits = 20
+
+var (
+	ErrTooLarge = errors.New("ipcalc: result too large")
+	ErrFull     = errors.New("ipcalc: no free address left")
+)
+
+// FromIPNet converts a net.IPNet, IPv4 networks are returned as IPv4 prefixes.
+func FromIPNet(n *net.IPNet) (netip.Prefix, error) {
+	if n == nil {
+		return netip.Prefix{}, fmt.Errorf("ipcalc: nil network")
+	}
+	addr, ok := netip.AddrFromSlice(n.IP)
+	if !ok {
+		return netip.Prefix{}, fmt.Errorf("ipcalc: invalid address %v", n.IP)
+	}
+	ones, err := MaskToPrefixLen(n.Mask)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	// the address and the mask may each be in the 4 or 16 bytes form
+	switch {
+	case len(n.Mask) == net.IPv4len && addr.Is4In6():
+		addr = addr.Unmap()
+	case len(n.Mask) == net.IPv4len && !addr.Is4():
+		return netip.Prefix{}, fmt.Errorf("ipcalc: IPv4 mask %s for %s", n.Mask, addr)
+	case len(n.Mask) == net.IPv6len && addr.Is4():
+		addr = netip.AddrFrom16(addr.As16())
+	}
+	return unmapPrefix(netip.PrefixFrom(addr, ones)), nil
+}
+
+// ToIPNet converts a prefix to a net.IPNet, keeping the address as given.
+func ToIPNet(p netip.Prefix) *net.IPNet {
+	return &net.IPNet{IP: net.IP(p.Addr().AsSlice()), Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen())}
+}
+
+// MaskToPrefixLen returns the prefix length of a canonical mask (255.255.255.0 is 24).
+func MaskToPrefixLen(mask net.IPMask) (int, error) {
+	ones, bits := mask.Size()
+	if bits == 0 {
+		return 0, fmt.Errorf("ipcalc: non canonical mask %s", mask)
+	}
+	return ones, nil
+}
+
+// PrefixLenToMask returns the mask of a prefix length, for IPv4 (bits 32) or IPv6 (bits 128).
+func PrefixLenToMask(ones, bits int) (net.IPMask, error) {
+	mask := net.CIDRMask(ones, bits)
+	if mask == nil {
+		return nil, fmt.Errorf("ipcalc: invalid prefix length /%d for %d bits", ones, bits)
+	}
+	return mask, nil
+}
+
+/*
+ParseMask returns the prefix length of a mask written as 255.255.255.0,
+ffff:ffff:ffff:ffff::, ffffff00 (net.IPMask.String) or /24, such as the values
+IfaceDetails.Field returns for IfaceMask.
+*/
+func ParseMask(s string) (int, error) {
+	if strings.HasPrefix(s, "/") {
+		ones, err := strconv.Atoi(s[1:])
+		if err != nil || ones < 0 || ones > 128 {
+			return 0, fmt.Errorf("ipcalc: invalid mask %q", s)
+		}
+		return ones, nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil && !strings.Contains(s, ":") {
+			ip = ip4
+		}
+		return MaskToPrefixLen(net.IPMask(ip))
+	}
+	if len(s) == 8 || len(s) == 32 {
+		mask := make(net.IPMask, len(s)/2)
+		for i := range mask {
+			b, err := strconv.ParseUint(s[2*i:2*i+2], 16, 8)
+			if err != nil {
+				return 0, fmt.Errorf("ipcalc: invalid mask %q", s)
+			}
+			mask[i] = byte(b)
+		}
+		return MaskToPrefixLen(mask)
+	}
+	return 0, fmt.Errorf("ipcalc: invalid mask %q", s)
+}
+
+// CIDR joins an address and a mask in any ParseMask form into a prefix, keeping the host bits (192.168.1.10/24).
+func CIDR(ip, mask string) (netip.Prefix, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ones, err := ParseMask(mask)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	if ones > addr.BitLen() {
+		return netip.Prefix{}, fmt.Errorf("ipcalc: mask %q does not fit %s", mask, addr)
+	}
+	return netip.PrefixFrom(addr, ones), nil
+}
+
+// Contains reports whether inner lies entirely in outer.
+func Contains(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+// Overlaps reports whether the two prefixes share any address.
+func Overlaps(a, b netip.Prefix) bool {
+	return a.Overlaps(b)
+}
+
+// ContainsNet is Contains for net.IPNet.
+func ContainsNet(outer, inner *net.IPNet) bool {
+	o, err1 := FromIPNet(outer)
+	i, err2 := FromIPNet(inner)
+	return err1 == nil && err2 == nil && Contains(o, i)
+}
+
+// OverlapsNet is Overlaps for net.IPNet.
+func OverlapsNet(a, b *net.IPNet) bool {
+	pa, err1 := FromIPNet(a)
+	pb, err2 := FromIPNet(b)
+	return err1 == nil && err2 == nil && Overlaps(pa, pb)
+}
+
+// Network returns the first address of the prefix (host bits cleared).
+func Network(p netip.Prefix) netip.Addr {
+	return p.Masked().Addr()
+}
+
+// Broadcast returns the last address of the prefix (host bits set), the IPv4 broadcast address.
+func Broadcast(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+/*
+EachHost calls fn for every usable host address of p in order, until fn returns
+false. The network and broadcast addresses of IPv4 prefixes up to /30 are skipped,
+as is the subnet-router anycast address of IPv6 prefixes up to /126.
+*/
+func EachHost(p netip.Prefix, fn func(netip.Addr) bool) {
+	p = p.Masked()
+	first, last := p.Addr(), Broadcast(p)
+	hostBits := p.Addr().BitLen() - p.Bits()
+	if hostBits >= 2 {
+		first = first.Next()
+		if p.Addr().Is4() {
+			last = last.Prev()
+		}
+	}
+	for a := first; a.IsValid() && a.Compare(last) <= 0; a = a.Next() {
+		if !fn(a) {
+			return
+		}
+	}
+}
+
+// Hosts lists the usable host addresses of p, see EachHost.
+func Hosts(p netip.Prefix) ([]netip.Addr, error) {
+	if hostBits := p.Addr().BitLen() - p.Bits(); hostBits > maxListBits {
+		return nil, ErrTooLarge
+	}
+	hosts := make([]netip.Addr, 0)
+	EachHost(p, func(a netip.Addr) bool {
+		hosts = append(hosts, a)
+		return true
+	})
+	return hosts, nil
+}
+
+// Split cuts p into the subnets of length bits (10.0.0.0/24 at /26 gives 4 subnets).
+func Split(p netip.Prefix, bits int) ([]netip.Prefix, error) {
+	p = p.Masked()
+	if bits < p.Bits() || bits > p.Addr().BitLen() {
+		return nil, fmt.Errorf("ipcalc: can not split %s into /%d", p, bits)
+	}
+	if bits-p.Bits() > maxListBits {
+		return nil, ErrTooLarge
+	}
+	subnets := make([]netip.Prefix, 0, 1<<(bits-p.Bits()))
+	for a := p.Addr(); a.IsValid() && p.Contains(a); {
+		sub := netip.PrefixFrom(a, bits)
+		subnets = append(subnets, sub)
+		a = Broadcast(sub).Next()
+	}
+	return subnets, nil
+}
+
+/*
+Summarize returns the smallest list of prefixes covering exactly the same
+addresses as the input: contained prefixes are dropped and adjacent siblings
+are merged (10.0.0.0/25 + 10.0.0.128/25 = 10.0.0.0/24).
+*/
+func Summarize(prefixes []netip.Prefix) []netip.Prefix {
+	list := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsValid() {
+			list = append(list, unmapPrefix(p).Masked())
+		}
+	}
+	for {
+		sortPrefixes(list)
+		merged := make([]netip.Prefix, 0, len(list))
+		changed := false
+		for _, p := range list {
+			if n := len(merged); n != 0 {
+				last := merged[n-1]
+				if Contains(last, p) {
+					changed = true
+					continue
+				}
+				if parent, ok := siblings(last, p); ok {
+					merged[n-1] = parent
+					changed = true
+					continue
+				}
+			}
+			merged = append(merged, p)
+		}
+		list = merged
+		if !changed {
+			return list
+		}
+	}
+}
+
+/*
+NextFree returns the first host address of p that is not in used, for static
+address allocation.
+*/
+func NextFree(p netip.Prefix, used []netip.Addr) (netip.Addr, error) {
+	taken := make(map[netip.Addr]bool, len(used))
+	for _, a := range used {
+		taken[a.Unmap()] = true
+	}
+	var free netip.Addr
+	EachHost(p, func(a netip.Addr) bool {
+		if taken[a] {
+			return true
+		}
+		free = a
+		return false
+	})
+	if !free.IsValid() {
+		return free, ErrFull
+	}
+	return free, nil
+}
+
+// unmapPrefix turns an IPv4-mapped IPv6 prefix into its IPv4 prefix, unless it is wider than the mapped range.
+func unmapPrefix(p netip.Prefix) netip.Prefix {
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		return netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p
+}
+
+func sortPrefixes(list []netip.Prefix) {
+	sort.Slice(list, func(i, j int) bool {
+		if c := list[i].Addr().Compare(list[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return list[i].Bits() < list[j].Bits()
+	})
+}
+
+// siblings reports whether a and b are the two halves of one parent prefix.
+func siblings(a, b netip.Prefix) (netip.Prefix, bool) {
+	if a.Bits() != b.Bits() || a.Bits() == 0 || a.Addr().Is4() != b.Addr().Is4() {
+		return netip.Prefix{}, false
+	}
+	parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+	if parent.Addr() != a.Addr() || !parent.Contains(b.Addr()) {
+		return netip.Prefix{}, false
+	}
+	return parent, true
+}
@@ -0,0 +1,172 @@
+package ipcalc
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func prefixes(list ...string) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		out = append(out, netip.MustParsePrefix(s))
+	}
+	return out
+}
+
+func TestMasks(t *testing.T) {
+	tests := map[string]int{
+		"255.255.255.0":         24,
+		"255.255.255.252":       30,
+		"ffff:ffff:ffff:ffff::": 64,
+		"ffffff00":              24,
+		"/20":                   20,
+	}
+	for mask, want := range tests {
+		if got, err := ParseMask(mask); err != nil || got != want {
+			t.Errorf("ParseMask(%q) = %d, %v, want %d", mask, got, err, want)
+		}
+	}
+	if _, err := ParseMask("255.0.255.0"); err == nil {
+		t.Error("non canonical mask accepted")
+	}
+	p, err := CIDR("192.168.1.10", "255.255.255.0")
+	if err != nil || p.String() != "192.168.1.10/24" {
+		t.Errorf("CIDR = %v, %v", p, err)
+	}
+}
+
+func TestIPNetConversion(t *testing.T) {
+	_, n, _ := net.ParseCIDR("10.1.0.0/16")
+	p, err := FromIPNet(n)
+	if err != nil || p != netip.MustParsePrefix("10.1.0.0/16") {
+		t.Errorf("FromIPNet = %v, %v", p, err)
+	}
+	mapped := &net.IPNet{IP: net.ParseIP("10.1.0.0"), Mask: net.CIDRMask(112, 128)}
+	if p, err := FromIPNet(mapped); err != nil || p != netip.MustParsePrefix("10.1.0.0/16") {
+		t.Errorf("FromIPNet(mapped) = %v, %v", p, err)
+	}
+	if back := ToIPNet(p); back.String() != n.String() {
+		t.Errorf("ToIPNet = %v, want %v", back, n)
+	}
+	_, inner, _ := net.ParseCIDR("10.1.2.0/24")
+	if !ContainsNet(n, inner) || ContainsNet(inner, n) || !OverlapsNet(inner, n) {
+		t.Error("ContainsNet/OverlapsNet")
+	}
+}
+
+func TestNetworkBroadcast(t *testing.T) {
+	p := netip.MustParsePrefix("192.168.1.77/26")
+	if got := Network(p).String(); got != "192.168.1.64" {
+		t.Errorf("Network = %s", got)
+	}
+	if got := Broadcast(p).String(); got != "192.168.1.127" {
+		t.Errorf("Broadcast = %s", got)
+	}
+	if got := Broadcast(netip.MustParsePrefix("fd00::/64")).String(); got != "fd00::ffff:ffff:ffff:ffff" {
+		t.Errorf("Broadcast v6 = %s", got)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	tests := map[string][]string{
+		"10.0.0.0/30": {"10.0.0.1", "10.0.0.2"},
+		"10.0.0.0/31": {"10.0.0.0", "10.0.0.1"},
+		"10.0.0.5/32": {"10.0.0.5"},
+		"fd00::/126":  {"fd00::1", "fd00::2", "fd00::3"},
+		"10.0.0.9/29": {"10.0.0.9", "10.0.0.10", "10.0.0.11", "10.0.0.12", "10.0.0.13", "10.0.0.14"},
+	}
+	for p, want := range tests {
+		hosts, err := Hosts(netip.MustParsePrefix(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0, len(hosts))
+		for _, h := range hosts {
+			got = append(got, h.String())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Hosts(%s) = %v, want %v", p, got, want)
+		}
+	}
+	if _, err := Hosts(netip.MustParsePrefix("fd00::/64")); err != ErrTooLarge {
+		t.Errorf("Hosts(/64) err = %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	got, err := Split(netip.MustParsePrefix("10.0.0.0/24"), 26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := prefixes("10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Split = %v, want %v", got, want)
+	}
+	if got, _ := Split(netip.MustParsePrefix("255.255.255.254/31"), 32); len(got) != 2 {
+		t.Errorf("Split at the end of the address space = %v", got)
+	}
+	if _, err := Split(netip.MustParsePrefix("10.0.0.0/24"), 16); err == nil {
+		t.Error("split to a shorter prefix accepted")
+	}
+}
+
+func TestFromIPNet4In6(t *testing.T) {
+	mapped := net.ParseIP("10.1.2.3")
+	tests := []struct {
+		n    *net.IPNet
+		want string // "" for an error
+	}{
+		{&net.IPNet{IP: mapped, Mask: net.CIDRMask(24, 32)}, "10.1.2.3/24"},
+		{&net.IPNet{IP: mapped.To4(), Mask: net.CIDRMask(120, 128)}, "10.1.2.3/24"},
+		{&net.IPNet{IP: mapped, Mask: net.CIDRMask(96, 128)}, "10.1.2.3/0"},
+		{&net.IPNet{IP: mapped, Mask: net.CIDRMask(80, 128)}, "::ffff:10.1.2.3/80"},
+		{&net.IPNet{IP: mapped.To4(), Mask: net.CIDRMask(64, 128)}, "::ffff:10.1.2.3/64"},
+		{&net.IPNet{IP: net.ParseIP("fd00::1"), Mask: net.CIDRMask(24, 32)}, ""},
+	}
+	for _, tt := range tests {
+		p, err := FromIPNet(tt.n)
+		if (tt.want == "" && err == nil) || (tt.want != "" && (err != nil || p.String() != tt.want)) {
+			t.Errorf("FromIPNet(%v/%v) = %v, %v, want %q", tt.n.IP, tt.n.Mask, p, err, tt.want)
+		}
+	}
+}
+
+func TestSummarize4In6(t *testing.T) {
+	tests := []struct {
+		in   []netip.Prefix
+		want []netip.Prefix
+	}{
+		{prefixes("10.0.0.0/24", "::ffff:10.0.1.0/120"), prefixes("10.0.0.0/23")},
+		{prefixes("::ffff:10.0.0.0/121", "::ffff:10.0.0.128/121"), prefixes("10.0.0.0/24")},
+		{prefixes("10.0.0.0/8", "::ffff:0:0/80"), prefixes("10.0.0.0/8", "::/80")},
+	}
+	for _, tt := range tests {
+		if got := Summarize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Summarize(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	got := Summarize(prefixes("10.0.1.0/24", "10.0.0.128/25", "10.0.0.0/25", "10.0.1.7/32", "192.168.0.0/24", "fd00::/65", "fd00::8000:0:0:0/65"))
+	want := prefixes("10.0.0.0/23", "192.168.0.0/24", "fd00::/64")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Summarize = %v, want %v", got, want)
+	}
+	if !Overlaps(want[0], netip.MustParsePrefix("10.0.1.128/25")) || Contains(want[0], netip.MustParsePrefix("10.0.0.0/22")) {
+		t.Error("Overlaps/Contains")
+	}
+}
+
+func TestNextFree(t *testing.T) {
+	p := netip.MustParsePrefix("192.168.1.0/29")
+	used := []netip.Addr{netip.MustParseAddr("192.168.1.1"), netip.MustParseAddr("192.168.1.2"), netip.MustParseAddr("192.168.1.4")}
+	if got, err := NextFree(p, used); err != nil || got.String() != "192.168.1.3" {
+		t.Errorf("NextFree = %v, %v", got, err)
+	}
+	full := netip.MustParsePrefix("192.168.1.0/30")
+	if _, err := NextFree(full, used); err != ErrFull {
+		t.Errorf("NextFree on a full prefix err = %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package gonetlibs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConnState is the connectivity of an interface seen by a ConnectivityMonitor, known states go from best to worst.
+type ConnState int
+
+const (
+	ConnUnknown  ConnState = iota // not checked yet
+	ConnOnline                    // enough targets answer
+	ConnDegraded                  // some targets answer, or all of them too slowly
+	ConnOffline                   // no target answers
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnUnknown:
+		return "unknown"
+	case ConnOnline:
+		return "online"
+	case ConnDegraded:
+		return "degraded"
+	case ConnOffline:
+		return "offline"
+	}
+	return fmt.Sprintf("ConnState(%d)", int(s))
+}
+
+// ConnCheck is the result of one round of probes.
+type ConnCheck struct {
+	Time      time.Time
+	Reachable int           // targets that answered
+	Total     int           // targets probed
+	RTT       time.Duration // fastest answer, 0 if none
+	State     ConnState     // what this check alone says, before hysteresis
+}
+
+// ConnEvent is a state change of a ConnectivityMonitor.
+type ConnEvent struct {
+	Iface string
+	State ConnState
+	Prev  ConnState
+	Time  time.Time
+	Check ConnCheck // the check that confirmed the change
+}
+
+func (e ConnEvent) String() string {
+	return fmt.Sprintf("%s %s -> %s (%d/%d targets)", e.Iface, e.Prev, e.State, e.Check.Reachable, e.Check.Total)
+}
+
+// MonitorOptions tunes NewConnectivityMonitor. A nil *MonitorOptions uses the defaults.
+type MonitorOptions struct {
+	Checker      *ConnectivityChecker // probes of each check, replaces Targets, TCP and Timeout
+	Targets      []string             // hosts to probe, default the public DNS servers of Family
+	Family       IPFamily             // IPv4 when unset like NetIsOnlinePing
+	TCP          bool                 // open tcp connections (host[:port], port 80 by default) like NetIsOnlineTcp instead of pinging
+	Interval     time.Duration        // between checks, default 5s
+	Timeout      time.Duration        // per probe, default 500ms
+	MinReachable int                  // targets that must answer to be online, default half of them
+	DegradedRTT  time.Duration        // an answer slower than this is degraded, 0 disables
+	Rise         int                  // checks in a row needed to move to a better state, default 2
+	Fall         int                  // checks in a row needed to move to a worse state, default 3
+	History      int                  // state changes kept, default 100
+}
+
+/*
+ConnectivityMonitor checks the connectivity of one interface in the background
+and reports online/degraded/offline changes. Hysteresis (Rise, Fall) keeps a
+flapping link from firing an event per check.
+*/
+type ConnectivityMonitor struct {
+	iface     string
+	opts      MonitorOptions
+	checker   *ConnectivityChecker
+	mutex     sync.Mutex
+	state     ConnState
+	last      ConnCheck
+	pending   ConnState // state the checks in a row disagreeing with state lead to
+	streak    int
+	history   []ConnEvent
+	callbacks []func(ConnEvent)
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewConnectivityMonitor returns a monitor of iface ("" for the default route), Start runs it.
+func NewConnectivityMonitor(iface string, opts *MonitorOptions) *ConnectivityMonitor {
+	var o MonitorOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Family == IPFamilyAny {
+		o.Family = IPFamilyV4
+	}
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Second
+	}
+	checker := &ConnectivityChecker{}
+	if o.Checker != nil {
+		*checker = *o.Checker
+		if len(iface) != 0 {
+			checker.Iface = iface
+		}
+	} else {
+		if len(o.Targets) == 0 {
+			o.Targets = dnsServers(o.Family)
+		}
+		method := CheckICMP
+		if o.TCP {
+			method = CheckTCP
+		}
+		for _, target := range o.Targets {
+			checker.Targets = append(checker.Targets, CheckTarget{Method: method, Addr: target})
+		}
+		checker.Iface, checker.Family, checker.Timeout = iface, o.Family, o.Timeout
+	}
+	if o.MinReachable <= 0 {
+		o.MinReachable = (len(checker.Targets) + 1) / 2
+	}
+	o.MinReachable = min(o.MinReachable, len(checker.Targets))
+	if o.Rise <= 0 {
+		o.Rise = 2
+	}
+	if o.Fall <= 0 {
+		o.Fall = 3
+	}
+	if o.History <= 0 {
+		o.History = 100
+	}
+	return &ConnectivityMonitor{iface: iface, opts: o, checker: checker}
+}
+
+// OnChange registers fn to be called on every state change, from the monitor goroutine.
+func (m *ConnectivityMonitor) OnChange(fn func(ConnEvent)) {
+	m.mutex.Lock()
+	m.callbacks = append(m.callbacks, fn)
+	m.mutex.Unlock()
+}
+
+// Start checks right away, then every Interval until Stop or ctx is done. Starting a running monitor does nothing.
+func (m *ConnectivityMonitor) Start(ctx context.Context) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.done != nil {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	go m.run(ctx, m.done)
+}
+
+// Stop stops the monitor and waits for the check in progress.
+func (m *ConnectivityMonitor) Stop() {
+	m.mutex.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mutex.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// State returns the current state.
+func (m *ConnectivityMonitor) State() ConnState {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.state
+}
+
+// LastCheck returns the result of the latest check.
+func (m *ConnectivityMonitor) LastCheck() ConnCheck {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.last
+}
+
+// History returns the latest state changes, oldest first.
+func (m *ConnectivityMonitor) History() []ConnEvent {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]ConnEvent(nil), m.history...)
+}
+
+func (m *ConnectivityMonitor) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+	for {
+		c := m.check(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		m.update(c)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check probes every target at once and classifies the answers.
+func (m *ConnectivityMonitor) check(ctx context.Context) ConnCheck {
+	report := m.checker.Check(ctx)
+	c := ConnCheck{Time: time.Now(), Reachable: report.Reachable, Total: len(report.Results), RTT: report.RTT}
+	c.State = m.classify(c)
+	return c
+}
+
+// classify tells the state of a single check.
+func (m *ConnectivityMonitor) classify(c ConnCheck) ConnState {
+	switch {
+	case c.Reachable == 0:
+		return ConnOffline
+	case c.Reachable < m.opts.MinReachable, m.opts.DegradedRTT > 0 && c.RTT > m.opts.DegradedRTT:
+		return ConnDegraded
+	}
+	return ConnOnline
+}
+
+// update applies the hysteresis to a check and fires the callbacks on a change.
+func (m *ConnectivityMonitor) update(c ConnCheck) {
+	m.mutex.Lock()
+	m.last = c
+	if c.State == m.state {
+		m.streak = 0
+		m.mutex.Unlock()
+		return
+	}
+	if worse := c.State > m.state; worse && m.pending > m.state && m.streak > 0 {
+		// every check worse than the state counts toward Fall, a flapping link moves to the mildest state seen
+		m.pending = min(m.pending, c.State)
+	} else if c.State != m.pending {
+		m.pending, m.streak = c.State, 0
+	}
+	m.streak++
+	// the first check decides right away, then better states need Rise checks and worse ones Fall
+	need := m.opts.Fall
+	if m.state == ConnUnknown {
+		need = 1
+	} else if m.pending < m.state {
+		need = m.opts.Rise
+	}
+	if m.streak < need {
+		m.mutex.Unlock()
+		return
+	}
+	e := ConnEvent{Iface: m.iface, State: m.pending, Prev: m.state, Time: c.Time, Check: c}
+	m.state, m.streak = m.pending, 0
+	m.history = append(m.history, e)
+	if len(m.history) > m.opts.History {
+		m.history = m.history[len(m.history)-m.opts.History:]
+	}
+	callbacks := append([]func(ConnEvent){}, m.callbacks...)
+	m.mutex.Unlock()
+	for _, fn := range callbacks {
+		fn(e)
+	}
+}
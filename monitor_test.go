@@ -0,0 +1,98 @@
+package gonetlibs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConnectivityMonitorHysteresis(t *testing.T) {
+	m := NewConnectivityMonitor("eth0", &MonitorOptions{Targets: []string{"192.0.2.1", "192.0.2.2"}, Rise: 2, Fall: 3})
+	var events []ConnEvent
+	m.OnChange(func(e ConnEvent) { events = append(events, e) })
+	states := []ConnState{ConnOnline, ConnOffline, ConnOffline, ConnOnline, ConnOffline, ConnOffline, ConnOffline,
+		ConnDegraded, ConnOnline, ConnOnline}
+	for _, s := range states {
+		m.update(ConnCheck{State: s})
+	}
+	want := []ConnState{ConnOnline, ConnOffline, ConnOnline}
+	if len(events) != len(want) {
+		t.Fatalf("events %v, want states %v", events, want)
+	}
+	for i, e := range events {
+		if e.State != want[i] {
+			t.Errorf("event %d: %s", i, e)
+		}
+	}
+	if m.State() != ConnOnline || len(m.History()) != 3 || m.History()[1].Prev != ConnOnline {
+		t.Errorf("state %s, history %v", m.State(), m.History())
+	}
+}
+
+func TestConnectivityMonitorFlapping(t *testing.T) {
+	m := NewConnectivityMonitor("eth0", &MonitorOptions{Targets: []string{"192.0.2.1", "192.0.2.2"}, Rise: 2, Fall: 3})
+	var events []ConnEvent
+	m.OnChange(func(e ConnEvent) { events = append(events, e) })
+	m.update(ConnCheck{State: ConnOnline})
+	// no two checks in a row agree, but none of them is online
+	for i := 0; i < 6; i++ {
+		s := ConnOffline
+		if i%2 == 1 {
+			s = ConnDegraded
+		}
+		m.update(ConnCheck{State: s})
+	}
+	if len(events) != 2 || events[1].State != ConnDegraded || events[1].Prev != ConnOnline {
+		t.Fatalf("events %v", events)
+	}
+	if m.State() != ConnDegraded {
+		t.Errorf("state %s", m.State())
+	}
+}
+
+func TestConnectivityMonitorDefaultFamily(t *testing.T) {
+	for _, opts := range []*MonitorOptions{nil, {Interval: time.Second}} {
+		m := NewConnectivityMonitor("", opts)
+		if m.checker.Family != IPFamilyV4 || len(m.checker.Targets) != len(dnslist) || m.opts.MinReachable != (len(dnslist)+1)/2 {
+			t.Errorf("opts %v: family %v, %d targets, MinReachable %d", opts, m.checker.Family, len(m.checker.Targets), m.opts.MinReachable)
+		}
+	}
+}
+
+func TestConnectivityMonitorClassify(t *testing.T) {
+	m := NewConnectivityMonitor("", &MonitorOptions{Targets: []string{"a", "b", "c", "d"}, DegradedRTT: 100 * time.Millisecond})
+	tests := []struct {
+		check ConnCheck
+		want  ConnState
+	}{
+		{ConnCheck{Reachable: 0, Total: 4}, ConnOffline},
+		{ConnCheck{Reachable: 1, Total: 4, RTT: time.Millisecond}, ConnDegraded},
+		{ConnCheck{Reachable: 2, Total: 4, RTT: time.Millisecond}, ConnOnline},
+		{ConnCheck{Reachable: 4, Total: 4, RTT: time.Second}, ConnDegraded},
+	}
+	for _, test := range tests {
+		if s := m.classify(test.check); s != test.want {
+			t.Errorf("classify(%+v) = %s, want %s", test.check, s, test.want)
+		}
+	}
+}
+
+func TestConnectivityMonitorLoopback(t *testing.T) {
+	m := NewConnectivityMonitor("", &MonitorOptions{Targets: []string{"127.0.0.1:1", "127.0.0.2:1"}, TCP: true, Interval: 20 * time.Millisecond})
+	changes := make(chan ConnEvent, 4)
+	m.OnChange(func(e ConnEvent) { changes <- e })
+	m.Start(context.Background())
+	defer m.Stop()
+	select {
+	case e := <-changes:
+		// a refused connection is not an answer for dialServer
+		if e.State != ConnOffline || e.Prev != ConnUnknown {
+			t.Errorf("first event %s", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+	if c := m.LastCheck(); c.Total != 2 {
+		t.Errorf("last check %+v", c)
+	}
+}
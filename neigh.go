@@ -0,0 +1,55 @@
+package gonetlibs
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/mannk98/gonetlibs/ipcalc"
+)
+
+// Neighbor is one entry of the kernel neighbor table (ARP for IPv4, NDP for IPv6).
+type Neighbor struct {
+	Family  int // 4 or 6
+	IP      net.IP
+	MAC     net.HardwareAddr // nil while the entry is incomplete or failed
+	Iface   string
+	IfIndex int
+	State   string // reachable, stale, delay, probe, incomplete, failed, noarp, permanent
+	Router  bool   // the neighbor announced itself as an IPv6 router
+}
+
+// ArpHost is a device that answered an ARP request during NetArpScan.
+type ArpHost struct {
+	IP  net.IP
+	MAC net.HardwareAddr
+	RTT time.Duration
+}
+
+// largest subnet NetArpScan and NetSweep agree to scan, in host bits
+const subnetMaxHostBits = 16
+
+// subnetHosts lists the host addresses of the IPv4 subnet of addr, except addr itself.
+func subnetHosts(addr IfaceAddr) ([]net.IP, error) {
+	return networkHosts(addr, true)
+}
+
+// networkHosts lists the host addresses of the IPv4 subnet of addr, without addr itself if exceptSelf.
+func networkHosts(addr IfaceAddr, exceptSelf bool) ([]net.IP, error) {
+	if addr.IP.To4() == nil {
+		return nil, fmt.Errorf("%s is not an ipv4 address", addr.IP)
+	}
+	if 32-addr.PrefixLen > subnetMaxHostBits {
+		return nil, fmt.Errorf("subnet %s is too large to scan", addr.CIDR())
+	}
+	self := addr.Prefix()
+	targets := make([]net.IP, 0)
+	ipcalc.EachHost(self, func(a netip.Addr) bool {
+		if !exceptSelf || a != self.Addr() {
+			targets = append(targets, net.IP(a.AsSlice()))
+		}
+		return true
+	})
+	return targets, nil
+}
@@ -0,0 +1,238 @@
+package gonetlibs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var neighStates = []struct {
+	state uint16
+	name  string
+}{
+	{unix.NUD_PERMANENT, "permanent"},
+	{unix.NUD_NOARP, "noarp"},
+	{unix.NUD_REACHABLE, "reachable"},
+	{unix.NUD_STALE, "stale"},
+	{unix.NUD_DELAY, "delay"},
+	{unix.NUD_PROBE, "probe"},
+	{unix.NUD_INCOMPLETE, "incomplete"},
+	{unix.NUD_FAILED, "failed"},
+}
+
+/*
+NetNeighbors dumps the kernel neighbor table (ARP and/or NDP), limited to the
+interface ifacenames[0] if given.
+*/
+func NetNeighbors(family IPFamily, ifacenames ...string) ([]Neighbor, error) {
+	var af uint8 = unix.AF_UNSPEC
+	switch family {
+	case IPFamilyV4:
+		af = unix.AF_INET
+	case IPFamilyV6:
+		af = unix.AF_INET6
+	}
+	names, err := ifaceNames()
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := nlDump(unix.RTM_GETNEIGH, af, unix.SizeofNdMsg)
+	if err != nil {
+		return nil, err
+	}
+	neighbors := make([]Neighbor, 0)
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWNEIGH || len(m.Data) < unix.SizeofNdMsg {
+			continue
+		}
+		n := Neighbor{Family: 4}
+		switch m.Data[0] {
+		case unix.AF_INET:
+		case unix.AF_INET6:
+			n.Family = 6
+		default:
+			continue
+		}
+		n.IfIndex = int(binary.NativeEndian.Uint32(m.Data[4:8]))
+		n.Iface = names[n.IfIndex]
+		if len(ifacenames) != 0 && n.Iface != ifacenames[0] {
+			continue
+		}
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+		for _, s := range neighStates {
+			if state&s.state != 0 {
+				n.State = s.name
+				break
+			}
+		}
+		n.Router = m.Data[10]&unix.NTF_ROUTER != 0
+		for _, a := range nlParseAttrs(m.Data[unix.SizeofNdMsg:]) {
+			switch a.Type {
+			case unix.NDA_DST:
+				n.IP = append(net.IP(nil), a.Value...)
+			case unix.NDA_LLADDR:
+				n.MAC = append(net.HardwareAddr(nil), a.Value...)
+			}
+		}
+		if n.IP == nil || n.IP.IsUnspecified() || n.IP.IsMulticast() {
+			continue
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+/*
+NetArpScan sends an ARP request to every address of the IPv4 subnet of interface
+ifacename over an AF_PACKET socket, and collects the answers received until
+timeout after the last request. Hosts that drop ICMP still answer ARP.
+Needs CAP_NET_RAW.
+*/
+func NetArpScan(ifacename string, timeout time.Duration) ([]ArpHost, error) {
+	d, err := NetInterfaceDetails(ifacename)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.MAC) != 6 {
+		return nil, fmt.Errorf("interface %s has no ethernet address", ifacename)
+	}
+	var local *IfaceAddr
+	for i := range d.Addrs {
+		if d.Addrs[i].Family == 4 {
+			local = &d.Addrs[i]
+			break
+		}
+	}
+	if local == nil {
+		return nil, fmt.Errorf("There isn't any ipv4 on interface %s", ifacename)
+	}
+	targets, err := subnetHosts(*local)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+	if err = unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: d.Index}); err != nil {
+		return nil, err
+	}
+	tv := unix.NsecToTimeval((100 * time.Millisecond).Nanoseconds())
+	if err = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+
+	var (
+		mutex = &sync.Mutex{}
+		sent  = make(map[[4]byte]time.Time, len(targets))
+		found = make(map[[4]byte]ArpHost)
+		done  = make(chan struct{})
+		wg    sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 1500)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			n, _, err := unix.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+					continue
+				}
+				return
+			}
+			ip, mac, ok := parseArpReply(buf[:n])
+			if !ok {
+				continue
+			}
+			mutex.Lock()
+			if start, asked := sent[ip]; asked {
+				if _, dup := found[ip]; !dup {
+					found[ip] = ArpHost{IP: net.IP(ip[:]), MAC: mac, RTT: time.Since(start)}
+				}
+			}
+			mutex.Unlock()
+		}
+	}()
+
+	to := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: d.Index, Halen: 6}
+	copy(to.Addr[:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	for i, target := range targets {
+		var key [4]byte
+		copy(key[:], target)
+		mutex.Lock()
+		sent[key] = time.Now()
+		mutex.Unlock()
+		if err = unix.Sendto(fd, arpRequest(d.MAC, local.IP.To4(), target), 0, to); err != nil {
+			break
+		}
+		if i%32 == 31 { // don't flood the link
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if err == nil {
+		time.Sleep(timeout)
+	}
+	close(done)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]ArpHost, 0, len(found))
+	for _, h := range found {
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return bytes.Compare(hosts[i].IP, hosts[j].IP) < 0
+	})
+	return hosts, nil
+}
+
+// arpRequest builds an ethernet frame carrying an ARP who-has for target.
+func arpRequest(srcMAC net.HardwareAddr, srcIP, target net.IP) []byte {
+	b := make([]byte, 42)
+	copy(b[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(b[6:12], srcMAC)
+	binary.BigEndian.PutUint16(b[12:14], unix.ETH_P_ARP)
+	binary.BigEndian.PutUint16(b[14:16], 1)      // hardware type ethernet
+	binary.BigEndian.PutUint16(b[16:18], 0x0800) // protocol type ipv4
+	b[18], b[19] = 6, 4
+	binary.BigEndian.PutUint16(b[20:22], 1) // request
+	copy(b[22:28], srcMAC)
+	copy(b[28:32], srcIP.To4())
+	copy(b[38:42], target.To4())
+	return b
+}
+
+// parseArpReply decodes an ethernet frame holding an ARP reply.
+func parseArpReply(b []byte) (ip [4]byte, mac net.HardwareAddr, ok bool) {
+	if len(b) < 42 || binary.BigEndian.Uint16(b[12:14]) != unix.ETH_P_ARP {
+		return ip, nil, false
+	}
+	if binary.BigEndian.Uint16(b[16:18]) != 0x0800 || b[18] != 6 || b[19] != 4 || binary.BigEndian.Uint16(b[20:22]) != 2 {
+		return ip, nil, false
+	}
+	copy(ip[:], b[28:32])
+	return ip, append(net.HardwareAddr(nil), b[22:28]...), true
+}
+
+func htons(v uint16) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return binary.NativeEndian.Uint16(b)
+}
@@ -0,0 +1,19 @@
+package gonetlibs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseArpReply(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x05}
+	frame := arpRequest(mac, net.ParseIP("192.168.10.1"), net.ParseIP("192.168.10.5"))
+	if _, _, ok := parseArpReply(frame); ok {
+		t.Fatal("a request was taken for a reply")
+	}
+	frame[21] = 2 // turn it into a reply
+	ip, got, ok := parseArpReply(frame)
+	if !ok || net.IP(ip[:]).String() != "192.168.10.1" || got.String() != mac.String() {
+		t.Errorf("parseArpReply = %v %v %v", ip, got, ok)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package gonetlibs
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// NetNeighbors needs rtnetlink and is only available on Linux.
+func NetNeighbors(family IPFamily, ifacenames ...string) ([]Neighbor, error) {
+	return nil, fmt.Errorf("NetNeighbors is not supported on %s", runtime.GOOS)
+}
+
+// NetArpScan needs AF_PACKET sockets and is only available on Linux.
+func NetArpScan(ifacename string, timeout time.Duration) ([]ArpHost, error) {
+	return nil, fmt.Errorf("NetArpScan is not supported on %s", runtime.GOOS)
+}
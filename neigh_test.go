@@ -0,0 +1,27 @@
+package gonetlibs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSubnetHosts(t *testing.T) {
+	addr := IfaceAddr{IP: net.ParseIP("192.168.10.5").To4(), PrefixLen: 29, Family: 4}
+	targets, err := subnetHosts(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"192.168.10.1", "192.168.10.2", "192.168.10.3", "192.168.10.4", "192.168.10.6"}
+	if len(targets) != len(want) {
+		t.Fatalf("got %v, want %v", targets, want)
+	}
+	for i, ip := range targets {
+		if ip.String() != want[i] {
+			t.Errorf("target %d is %s, want %s", i, ip, want[i])
+		}
+	}
+
+	if _, err := subnetHosts(IfaceAddr{IP: net.ParseIP("10.0.0.1").To4(), PrefixLen: 8}); err == nil {
+		t.Error("a /8 should be refused")
+	}
+}
@@ -0,0 +1,181 @@
+package gonetlibs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// nlAttr is one rtnetlink attribute (struct rtattr / struct nlattr).
+type nlAttr struct {
+	Type  uint16
+	Value []byte
+}
+
+// nlConn is a NETLINK_ROUTE socket, used for dumps, single requests and
+// multicast subscriptions.
+type nlConn struct {
+	fd  int
+	pid uint32
+	seq uint32
+}
+
+func nlAlign(n int) int {
+	return (n + unix.NLMSG_ALIGNTO - 1) & ^(unix.NLMSG_ALIGNTO - 1)
+}
+
+// nlOpen opens a rtnetlink socket, joined to the given RTMGRP_* groups (0 for none).
+func nlOpen(groups uint32) (*nlConn, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	if err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: groups}); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	sa, err := unix.Getsockname(fd)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	c := &nlConn{fd: fd}
+	if nsa, ok := sa.(*unix.SockaddrNetlink); ok {
+		c.pid = nsa.Pid
+	}
+	return c, nil
+}
+
+func (c *nlConn) Close() error {
+	return unix.Close(c.fd)
+}
+
+// send writes one request message to the kernel and returns its sequence number.
+func (c *nlConn) send(msgType, flags uint16, body []byte) (uint32, error) {
+	c.seq++
+	b := make([]byte, unix.NLMSG_HDRLEN+len(body))
+	binary.NativeEndian.PutUint32(b[0:4], uint32(len(b)))
+	binary.NativeEndian.PutUint16(b[4:6], msgType)
+	binary.NativeEndian.PutUint16(b[6:8], unix.NLM_F_REQUEST|flags)
+	binary.NativeEndian.PutUint32(b[8:12], c.seq)
+	binary.NativeEndian.PutUint32(b[12:16], c.pid)
+	copy(b[unix.NLMSG_HDRLEN:], body)
+	return c.seq, unix.Sendto(c.fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK})
+}
+
+// recv reads one datagram from the socket and splits it into messages.
+func (c *nlConn) recv() ([]syscall.NetlinkMessage, error) {
+	buf := make([]byte, 1<<16)
+	n, _, err := unix.Recvfrom(c.fd, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n < unix.NLMSG_HDRLEN {
+		return nil, fmt.Errorf("netlink: short message (%d bytes)", n)
+	}
+	return syscall.ParseNetlinkMessage(buf[:n])
+}
+
+// request sends a message and collects the replies. Dump replies are read
+// until NLMSG_DONE, a single reply ends at the first non-multipart message.
+func (c *nlConn) request(msgType, flags uint16, body []byte) ([]syscall.NetlinkMessage, error) {
+	seq, err := c.send(msgType, flags, body)
+	if err != nil {
+		return nil, err
+	}
+	var out []syscall.NetlinkMessage
+	for {
+		msgs, err := c.recv()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != seq || (m.Header.Pid != 0 && m.Header.Pid != c.pid) {
+				continue
+			}
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return out, nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return nil, fmt.Errorf("netlink: short error message")
+				}
+				if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				return out, nil
+			}
+			out = append(out, m)
+			if m.Header.Flags&unix.NLM_F_MULTI == 0 {
+				return out, nil
+			}
+		}
+	}
+}
+
+// nlRequest runs a single request on a throw-away socket.
+func nlRequest(msgType, flags uint16, body []byte) ([]syscall.NetlinkMessage, error) {
+	c, err := nlOpen(0)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.request(msgType, flags, body)
+}
+
+// nlDump dumps a kernel table (links, addresses, routes, neighbors) for an address family.
+func nlDump(msgType uint16, family uint8, hdrlen int) ([]syscall.NetlinkMessage, error) {
+	body := make([]byte, nlAlign(hdrlen))
+	body[0] = family
+	return nlRequest(msgType, unix.NLM_F_DUMP, body)
+}
+
+// nlParseAttrs decodes a run of attributes, nested ones are left as raw bytes.
+func nlParseAttrs(b []byte) []nlAttr {
+	var attrs []nlAttr
+	for len(b) >= unix.SizeofRtAttr {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < unix.SizeofRtAttr || l > len(b) {
+			break
+		}
+		attrs = append(attrs, nlAttr{
+			Type:  binary.NativeEndian.Uint16(b[2:4]) &^ (unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER),
+			Value: b[unix.SizeofRtAttr:l],
+		})
+		if al := nlAlign(l); al < len(b) {
+			b = b[al:]
+		} else {
+			break
+		}
+	}
+	return attrs
+}
+
+// nlEncodeAttr encodes one attribute, padded to the netlink alignment.
+func nlEncodeAttr(attrType uint16, value []byte) []byte {
+	l := unix.SizeofRtAttr + len(value)
+	b := make([]byte, nlAlign(l))
+	binary.NativeEndian.PutUint16(b[0:2], uint16(l))
+	binary.NativeEndian.PutUint16(b[2:4], attrType)
+	copy(b[unix.SizeofRtAttr:], value)
+	return b
+}
+
+// nlString decodes a NUL terminated string attribute.
+func nlString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+func nlUint32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.NativeEndian.Uint32(b)
+}
@@ -6,15 +6,12 @@ import (
 	"net"
 	"net/url"
 	"os"
-	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/mannk98/gonetlibs/mdns"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
 )
 
 const (
@@ -43,39 +40,53 @@ var dnslist = []string{
 	//	"64.6.64.6", "64.6.65.6"
 } // verisign
 
+var dnslist6 = []string{
+	"2606:4700:4700::1111", "2606:4700:4700::1001", //clouflare
+	"2001:4860:4860::8888", "2001:4860:4860::8844", //google
+}
+
 const (
 	// Stolen from https://godoc.org/golang.org/x/net/internal/iana,
 	// can't import "internal" packages
-	ProtocolICMP = 1
-	//ProtocolIPv6ICMP = 58
+	ProtocolICMP     = 1
+	ProtocolIPv6ICMP = 58
+)
+
+// IPv6 address scopes, used to pick an address of an interface.
+type Ip6Scope int
+
+const (
+	Ip6ScopeAny       Ip6Scope = iota // global, then ULA, then link-local
+	Ip6ScopeGlobal                    // global unicast, 2000::/3
+	Ip6ScopeULA                       // unique local, fc00::/7
+	Ip6ScopeLinkLocal                 // fe80::/10, returned with its zone (fe80::1%eth0)
 )
 
-// list all real network iface, include ethernet, wlan, docker network
-func NetListAllRealIface() ([]string, error) {
-	cmd := exec.Command("ip", "link", "show")
-	output, err := cmd.CombinedOutput()
+// IP family used by the connectivity checks.
+type IPFamily int
+
+const (
+	IPFamilyAny IPFamily = iota // IPv4 first, then IPv6
+	IPFamilyV4
+	IPFamilyV6
+)
+
+// list all real network iface, include ethernet, wlan, docker network.
+// filters choose which interfaces count as real, RealIfaceFilter is used if none given.
+func NetListAllRealIface(filters ...*IfaceFilter) ([]string, error) {
+	filter := RealIfaceFilter
+	if len(filters) != 0 {
+		filter = filters[0]
+	}
+	ifaces, err := NetListIfaces(filter)
 	if err != nil {
-		//("Error running ip command:", err)
 		return nil, err
 	}
-
-	// Parse the output to extract interface names
-	interfaceLines := strings.Split(string(output), "\n")
-	interfaces := make([]string, 0)
-
-	for _, line := range interfaceLines {
-		fields := strings.Fields(line)
-		if len(fields) >= 7 && fields[1] != "lo:" && !strings.Contains(fields[1], "veth") {
-			interfaces = append(interfaces, strings.TrimSuffix(fields[1], ":"))
-		}
+	interfaces := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		interfaces = append(interfaces, iface.Name)
 	}
-
-	// Print the list of network interfaces
-	//fmt.Println("Network Interfaces:")
-	//for _, iface := range interfaces {
-	//	fmt.Println(iface)
-	//}
-	return interfaces, err
+	return interfaces, nil
 }
 
 /* Get first finded IPv4 address of Linux network interface. */
@@ -85,7 +96,7 @@ func NetGetInterfaceIpv4Addr(interfaceName string) (addr string, err error) {
 		addrs    []net.Addr
 		ipv4Addr net.IP
 	)
-	if ief, err = net.InterfaceByName(interfaceName); err != nil { // get interface
+	if ief, err = ifaceByName(interfaceName); err != nil { // get interface
 		return
 	}
 	if addrs, err = ief.Addrs(); err != nil { // get addresses
@@ -97,11 +108,7 @@ func NetGetInterfaceIpv4Addr(interfaceName string) (addr string, err error) {
 		}
 	}
 	if ipv4Addr == nil {
-		if len(addrs) != 0 {
-			return "", fmt.Errorf(fmt.Sprintf("There isn't any ipv4 on interface %s\n", interfaceName))
-		} else {
-			return "", fmt.Errorf(fmt.Sprintf("There isn't any ip on interface %s\n", interfaceName))
-		}
+		return "", &IfaceError{Iface: interfaceName, Err: ErrNoIPv4, NoAddr: len(addrs) == 0}
 	}
 	return ipv4Addr.String(), nil
 }
@@ -114,48 +121,103 @@ func NetIfaceHasIpv4(interfaceName string) bool {
 	return false
 }
 
-/* Convert Domain to IP */
-func ResolverDomain(domain string, debugflag ...bool) (addrs []string, err error) {
-	if addr := net.ParseIP(domain); addr != nil {
-		return []string{domain}, nil
+/*
+Get IPv6 address of Linux network interface, in the first given scope that has one.
+Link-local addresses are returned with their zone: fe80::1%eth0.
+*/
+func NetGetInterfaceIpv6Addr(interfaceName string, scopes ...Ip6Scope) (addr string, err error) {
+	var (
+		ief   *net.Interface
+		addrs []net.Addr
+	)
+	if ief, err = ifaceByName(interfaceName); err != nil { // get interface
+		return
+	}
+	if addrs, err = ief.Addrs(); err != nil { // get addresses
+		return
+	}
+	if len(scopes) == 0 || (len(scopes) == 1 && scopes[0] == Ip6ScopeAny) {
+		scopes = []Ip6Scope{Ip6ScopeGlobal, Ip6ScopeULA, Ip6ScopeLinkLocal, Ip6ScopeAny}
 	}
+	for _, scope := range scopes {
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() != nil || (scope != Ip6ScopeAny && ip6ScopeOf(ipnet.IP) != scope) {
+				continue
+			}
+			// whatever the scope asked, a link-local address is only usable with its zone
+			if ipnet.IP.IsLinkLocalUnicast() {
+				return ipnet.IP.String() + "%" + interfaceName, nil
+			}
+			return ipnet.IP.String(), nil
+		}
+	}
+	return "", &IfaceError{Iface: interfaceName, Err: ErrNoIPv6, NoAddr: len(addrs) == 0}
+}
 
-	r := &net.Resolver{
-		PreferGo: true,
-		Dial:     nil,
+/* Check if Network Interface has any IPv6 address in the given scopes. */
+func NetIfaceHasIpv6(interfaceName string, scopes ...Ip6Scope) bool {
+	if _, err := NetGetInterfaceIpv6Addr(interfaceName, scopes...); err == nil {
+		return true
 	}
+	return false
+}
 
-	for i := 0; i < len(dnslist); i++ {
-		for _, pro := range []string{"udp", "tcp"} {
-			/* setup a custom dial for Resolver, return connection to host (udp or tcp) */
-			r.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
-				d := net.Dialer{
-					Timeout: time.Millisecond * time.Duration(5000),
-				}
-				return d.DialContext(ctx, pro, dnslist[i]+":53")
-			}
+func ip6ScopeOf(ip net.IP) Ip6Scope {
+	switch {
+	case ip.IsLinkLocalUnicast():
+		return Ip6ScopeLinkLocal
+	case ip.IsPrivate():
+		return Ip6ScopeULA
+	case ip.IsGlobalUnicast():
+		return Ip6ScopeGlobal
+	}
+	return Ip6ScopeAny
+}
 
-			/* start lookup using custom resolver, if success, return. */
-			if addrs, err = r.LookupHost(context.Background(), domain); err == nil {
-				return addrs, err
-			}
+/* Convert Domain to IP */
+func ResolverDomain(domain string, debugflag ...bool) (addrs []string, err error) {
+	return ResolverDomainContext(context.Background(), domain, debugflag...)
+}
 
-			if len(debugflag) != 0 && debugflag[0] {
-				log.Errorf("\nCan not used dns server %s for finding %s\n", dnslist[i], domain)
+/*
+Convert Domain to IP through DefaultResolver, giving up when ctx is done. Failures
+are *NetError of kind ErrDNS.
+*/
+func ResolverDomainContext(ctx context.Context, domain string, debugflag ...bool) (addrs []string, err error) {
+	debug := DefaultResolver.Debug || (len(debugflag) != 0 && debugflag[0])
+	return DefaultResolver.lookupHost(ctx, domain, debug)
+}
+
+func ResolverDomain2Ip4(domain string, debugflag ...bool) (addr string, err error) {
+	return ResolverDomain2Ip4Context(context.Background(), domain, debugflag...)
+}
+
+func ResolverDomain2Ip4Context(ctx context.Context, domain string, debugflag ...bool) (addr string, err error) {
+	if addrs, err := ResolverDomainContext(ctx, domain, debugflag...); err == nil {
+		for _, v := range addrs {
+			if strings.Contains(v, ".") {
+				return v, nil
 			}
 		}
+		return "", &NetError{Op: "lookup", Addr: domain, Kind: ErrDNS, Err: fmt.Errorf("there is not ipv4")}
+	} else {
+		return "", err
 	}
-	return net.LookupHost(domain) //system lockup if custome resolver fail.
 }
 
-func ResolverDomain2Ip4(domain string, debugflag ...bool) (addr string, err error) {
-	if addrs, err := ResolverDomain(domain, debugflag...); err == nil {
+func ResolverDomain2Ip6(domain string, debugflag ...bool) (addr string, err error) {
+	return ResolverDomain2Ip6Context(context.Background(), domain, debugflag...)
+}
+
+func ResolverDomain2Ip6Context(ctx context.Context, domain string, debugflag ...bool) (addr string, err error) {
+	if addrs, err := ResolverDomainContext(ctx, domain, debugflag...); err == nil {
 		for _, v := range addrs {
-			if strings.Contains(v, ".") {
+			if strings.Contains(v, ":") {
 				return v, nil
 			}
 		}
-		return "", fmt.Errorf("there is not ipv4")
+		return "", &NetError{Op: "lookup", Addr: domain, Kind: ErrDNS, Err: fmt.Errorf("there is not ipv6")}
 	} else {
 		return "", err
 	}
@@ -167,104 +229,160 @@ func ResolverDomain2Ip4(domain string, debugflag ...bool) (addr string, err erro
 return nil if cant connect to server through interface
 */
 func NetCheckConectionToServer(domain string, ifacenames ...string) error {
-	tcpAddr := &net.TCPAddr{}
+	return NetCheckConectionToServerFamily(domain, IPFamilyV4, ifacenames...)
+}
 
+/* Check connection to http/https server over IPv4, IPv6 or either of them */
+func NetCheckConectionToServerFamily(domain string, family IPFamily, ifacenames ...string) error {
+	return NetCheckConectionToServerContext(context.Background(), domain, family, ifacenames...)
+}
+
+/* Check connection to http/https server over a family until ctx is done, errors are *IfaceError or *NetError */
+func NetCheckConectionToServerContext(ctx context.Context, domain string, family IPFamily, ifacenames ...string) error {
+	ifacename := ""
 	if len(ifacenames) != 0 {
-		ip4add, err := NetGetInterfaceIpv4Addr(ifacenames[0])
-		if err != nil || len(ip4add) == 0 {
-			return err
-		} else {
-			tcpAddr.IP = net.ParseIP(ip4add)
-		}
-	} else {
-		tcpAddr = nil
+		ifacename = ifacenames[0]
+	}
+	return dialServer(ctx, domain, family, time.Millisecond*2000, ifacename)
+}
+
+/* Check if server is alive, timeout check is 666ms */
+func ServerIsLive(domain string, ifacenames ...string) bool {
+	return ServerIsLiveFamily(domain, IPFamilyV4, ifacenames...)
+}
+
+/* Check if server is alive over IPv4, IPv6 or either of them, timeout check is 666ms */
+func ServerIsLiveFamily(domain string, family IPFamily, ifacenames ...string) bool {
+	return ServerIsLiveContext(context.Background(), domain, family, ifacenames...)
+}
+
+/* Check if server is alive over a family, timeout check is 666ms or sooner when ctx is done */
+func ServerIsLiveContext(ctx context.Context, domain string, family IPFamily, ifacenames ...string) bool {
+	ifacename := ""
+	if len(ifacenames) != 0 {
+		ifacename = ifacenames[0]
 	}
+	return dialServer(ctx, domain, family, time.Millisecond*666, ifacename) == nil
+}
 
-	d := net.Dialer{LocalAddr: tcpAddr, Timeout: time.Millisecond * 2000}
+/* Open and close a tcp connection to domain (url or host[:port]), through interface ifacename if not empty */
+func dialServer(ctx context.Context, domain string, family IPFamily, timeout time.Duration, ifacename string) (err error) {
+	host, port, err := serverHostPort(domain)
+	if err != nil {
+		return err
+	}
+	conn, err := dialHost(ctx, "tcp", host, port, family, timeout, ifacename)
+	if err != nil {
+		return wrapNetError("dial", domain, err)
+	}
+	conn.Close()
+	return nil
+}
 
+/* Split domain (url or host[:port]) into host and port, the port defaults to the one of the url scheme */
+func serverHostPort(domain string) (host, port string, err error) {
 	if !strings.Contains(domain, "://") {
 		domain = "http://" + domain
 	}
 	u, err := url.Parse(domain)
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	port := "80"
+	port = "80"
 	if u.Scheme == "https" {
 		port = "443"
 	}
 
-	host := u.Host
+	host = u.Host
 	if thost, tport, _ := net.SplitHostPort(u.Host); len(thost) != 0 {
 		port = tport
 		host = thost
 	}
-	ip4, err := ResolverDomain2Ip4(host)
-	if err != nil {
-		//		log.Error(err, host)
+	return strings.Trim(host, "[]"), port, nil
+}
 
-		return err
+/* Dial host:port over network (tcp or udp) from the address of interface ifacename if not empty, IPv4 then IPv6 for IPFamilyAny */
+func dialHost(ctx context.Context, network, host, port string, family IPFamily, timeout time.Duration, ifacename string) (conn net.Conn, err error) {
+	families := []IPFamily{family}
+	if family == IPFamilyAny {
+		families = []IPFamily{IPFamilyV4, IPFamilyV6}
 	}
-	if conn, err := d.Dial("tcp", ip4+":"+port); err != nil {
-		//		log.Error(err)
-		return err
-	} else {
-		conn.Close()
-		return nil
+	for _, f := range families {
+		if conn, err = dialHostFamily(ctx, network, host, port, f, timeout, ifacename); err == nil {
+			return conn, nil
+		}
 	}
+	return nil, err
 }
 
-/* Check if server is alive, timeout check is 666ms */
-func ServerIsLive(domain string, ifacenames ...string) bool {
-	tcpAddr := &net.TCPAddr{}
+func dialHostFamily(ctx context.Context, network, host, port string, family IPFamily, timeout time.Duration, ifacename string) (net.Conn, error) {
+	var (
+		localAddr net.Addr
+		ip        string
+		err       error
+	)
+	if family == IPFamilyV6 {
+		network += "6"
+	} else {
+		network += "4"
+	}
 
-	if len(ifacenames) != 0 {
-		ip4add, err := NetGetInterfaceIpv4Addr(ifacenames[0])
-		if err != nil || len(ip4add) == 0 {
-			return false
+	if len(ifacename) != 0 {
+		var localip string
+		if family == IPFamilyV6 {
+			localip, err = NetGetInterfaceIpv6Addr(ifacename)
 		} else {
-			tcpAddr.IP = net.ParseIP(ip4add)
+			localip, err = NetGetInterfaceIpv4Addr(ifacename)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(network, "udp") {
+			localAddr, err = net.ResolveUDPAddr(network, net.JoinHostPort(localip, "0"))
+		} else {
+			localAddr, err = net.ResolveTCPAddr(network, net.JoinHostPort(localip, "0"))
+		}
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		tcpAddr = nil
 	}
 
-	d := net.Dialer{LocalAddr: tcpAddr, Timeout: time.Millisecond * 666}
-
-	if !strings.Contains(domain, "://") {
-		domain = "http://" + domain
+	if family == IPFamilyV6 {
+		ip, err = ResolverDomain2Ip6Context(ctx, host)
+	} else {
+		ip, err = ResolverDomain2Ip4Context(ctx, host)
 	}
-	u, err := url.Parse(domain)
 	if err != nil {
-		return false
-	}
-	port := "80"
-	if u.Scheme == "https" {
-		port = "443"
+		return nil, err
 	}
 
-	host := u.Host
-	if thost, tport, _ := net.SplitHostPort(u.Host); len(thost) != 0 {
-		port = tport
-		host = thost
-	}
-	ip4, err := ResolverDomain2Ip4(host)
-	if err != nil {
-		//		log.Error(err, host)
+	d := net.Dialer{Timeout: timeout, LocalAddr: localAddr}
+	return d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+}
 
-		return false
-	}
-	if conn, err := d.Dial("tcp", ip4+":"+port); err != nil {
-		//		log.Error(err)
-		return false
-	} else {
-		conn.Close()
-		return true
+// dnsServers lists the public DNS servers of a family used by the online checks.
+func dnsServers(family IPFamily) []string {
+	switch family {
+	case IPFamilyV6:
+		return dnslist6
+	case IPFamilyAny:
+		return append(append([]string{}, dnslist...), dnslist6...)
 	}
+	return dnslist
 }
 
 /* Check if host machine have internet (check http connection to DNS server ) */
 func NetIsOnlineTcp(times, intervalsecs int, ifacenames ...string) bool {
+	return NetIsOnlineTcpFamily(times, intervalsecs, IPFamilyV4, ifacenames...)
+}
+
+/* Check if host machine have internet over IPv4, IPv6 or either of them (check http connection to DNS servers) */
+func NetIsOnlineTcpFamily(times, intervalsecs int, family IPFamily, ifacenames ...string) bool {
+	return NetIsOnlineTcpContext(context.Background(), times, intervalsecs, family, ifacenames...)
+}
+
+/* Check if host machine have internet over a family (check http connection to DNS servers), false as soon as ctx is done */
+func NetIsOnlineTcpContext(ctx context.Context, times, intervalsecs int, family IPFamily, ifacenames ...string) bool {
 	ifacename := ""
 	if len(ifacenames) != 0 {
 		ifacename = ifacenames[0]
@@ -273,15 +391,17 @@ func NetIsOnlineTcp(times, intervalsecs int, ifacenames ...string) bool {
 	//	if sutils.StringContainsI(ifacename, "ppp") {
 	//		timeout = time.Millisecond * 3000
 	//	}
-	numDnsTest := len(dnslist)
+	servers := dnsServers(family)
+	numDnsTest := len(servers)
 	//	if numDnsTest >= 4 {
 	//		numDnsTest = 4
 	//	}
 	ttk := time.NewTicker(time.Second * time.Duration(intervalsecs))
+	defer ttk.Stop()
 	for i1 := 0; i1 < times; i1++ {
-		for i := 0; i < numDnsTest; i++ {
-			//			log.Warn("Ping interface ", ifacename, dnslist[i])
-			if ServerIsLive(dnslist[i], ifacename) {
+		for i := 0; i < numDnsTest && ctx.Err() == nil; i++ {
+			//			log.Warn("Ping interface ", ifacename, servers[i])
+			if ServerIsLiveContext(ctx, servers[i], family, ifacename) {
 				return true
 			} else {
 				//				log.Errorf("Error to use iface %s to test dns server: %s\n", ifacename, dnslist[i])
@@ -289,7 +409,11 @@ func NetIsOnlineTcp(times, intervalsecs int, ifacenames ...string) bool {
 			}
 		}
 		if times > 1 {
-			<-ttk.C
+			select {
+			case <-ttk.C:
+			case <-ctx.Done():
+				return false
+			}
 		}
 	}
 	return false
@@ -297,6 +421,16 @@ func NetIsOnlineTcp(times, intervalsecs int, ifacenames ...string) bool {
 
 /* Check if host machine have internet (check by ping(imcp) to dns servers) */
 func NetIsOnlinePing(times, intervalsecs int, ifacenames ...string) bool {
+	return NetIsOnlinePingFamily(times, intervalsecs, IPFamilyV4, ifacenames...)
+}
+
+/* Check if host machine have internet over IPv4, IPv6 or either of them (ping the anycast DNS servers) */
+func NetIsOnlinePingFamily(times, intervalsecs int, family IPFamily, ifacenames ...string) bool {
+	return NetIsOnlinePingContext(context.Background(), times, intervalsecs, family, ifacenames...)
+}
+
+/* Check if host machine have internet over a family (ping the anycast DNS servers), false as soon as ctx is done */
+func NetIsOnlinePingContext(ctx context.Context, times, intervalsecs int, family IPFamily, ifacenames ...string) bool {
 	ifacename := ""
 	if len(ifacenames) != 0 {
 		ifacename = ifacenames[0]
@@ -305,121 +439,90 @@ func NetIsOnlinePing(times, intervalsecs int, ifacenames ...string) bool {
 	//	if sutils.StringContainsI(ifacename, "ppp") {
 	//		timeout = time.Millisecond * 3000
 	//	}
-	numDnsTest := len(dnslist)
-	//	if numDnsTest >= 4 {
-	//		numDnsTest = 4
-	//	}
+	servers := dnsServers(family)
+	/* all the servers are pinged at once through one socket per family, the first reply wins */
+	engine := NewICMPEngine(&ICMPEngineOptions{Iface: ifacename, Timeout: timeout})
+	defer engine.Close()
 	ttk := time.NewTicker(time.Second * time.Duration(intervalsecs))
+	defer ttk.Stop()
 	for i1 := 0; i1 < times; i1++ {
-		for i := 0; i < numDnsTest; i++ {
-			//			log.Warn("Ping interface ", ifacename)
-			if _, _, err := Ping(dnslist[i], ifacename, timeout); err != nil {
-				//				if sutils.StringContainsI(ifacename, "ppp") {
-				//				log.Errorf("Error to use iface %s to test dns server: %s\n%s\n", ifacename, dnslist[i], err.Error())
-				//				}
-				continue
-			} else {
-				//				log.Warnf("Use iface %s to test dns server: %s\n", ifacename, dnslist[i])
-				return true
+		replies := make(chan bool, len(servers))
+		sent := 0
+		for _, server := range servers {
+			if err := engine.Send(&net.IPAddr{IP: net.ParseIP(server)}, timeout, func(r EchoResult) { replies <- r.Err == nil }); err == nil {
+				sent++
+			}
+		}
+		for i := 0; i < sent; i++ {
+			select {
+			case ok := <-replies:
+				if ok {
+					return true
+				}
+			case <-ctx.Done():
+				return false
 			}
 		}
 		if times > 1 {
-			<-ttk.C
+			select {
+			case <-ttk.C:
+			case <-ctx.Done():
+				return false
+			}
 		}
 	}
 	return false
 }
 
+/*
+Ping sends one echo request to addr through interface iface (any if empty) and
+waits timeouts[0] (1s by default) for the reply. Without CAP_NET_RAW it falls
+back to a datagram ICMP socket, see ICMPMode. IPv6 addresses are pinged over
+ICMPv6, domains over IPv4 when they have an IPv4 address. See Pinger for more
+options.
+*/
 func Ping(addr, iface string, timeouts ...time.Duration) (*net.IPAddr, time.Duration, error) {
-	// Start listening for icmp replies
-	timeout := time.Millisecond * 1000
-	if len(timeouts) != 0 {
-		timeout = timeouts[0]
-	}
-	listenAddr := "0.0.0.0"
-	if len(iface) != 0 {
-		// if sutils.StringContainsI(iface, "ppp") {
-		//			defer sutils.TimeTrack(time.Now())
-		// }
-		if ip4, err := NetGetInterfaceIpv4Addr(iface); err == nil {
-			//			fmt.Printf("\nip's%s is %s\n", iface, ip4)
-			listenAddr = ip4
-		} else {
-			return nil, 0, fmt.Errorf("iface %s don't have ipv4 address.", iface)
-		}
-	}
-	//	c, err := icmp.ListenPacket("ip4:1991", listenAddr+":1991")
-	c := new(icmp.PacketConn)
-	var err error
-	c, err = icmp.ListenPacket("ip4:icmp", listenAddr)
-	/* 	if sutils.GOOS != "windows" {
-	   		c, err = icmp.ListenPacket("udp4", listenAddr)
-	   	} else {
-	   		c, err = icmp.ListenPacket("ip4:icmp", listenAddr)
-	   	} */
-
-	if err != nil {
-		return nil, 0, err
-	}
-	defer c.Close()
+	return pingFamily(context.Background(), addr, iface, IPFamilyAny, timeouts...)
+}
 
-	// Resolve any DNS (if used) and get the real IP of the target
-	dstip4, err := ResolverDomain2Ip4(addr)
-	if err != nil {
-		//		panic(err)
-		return nil, 0, err
-	}
+/* Ping stopping early when ctx is done. Errors are *IfaceError or *NetError, a lost reply is ErrTimeout. */
+func PingContext(ctx context.Context, addr, iface string, timeouts ...time.Duration) (*net.IPAddr, time.Duration, error) {
+	return pingFamily(ctx, addr, iface, IPFamilyAny, timeouts...)
+}
 
-	dst, err := net.ResolveIPAddr("ip4", dstip4)
-	if err != nil {
-		//		panic(err)
-		return nil, 0, err
-	}
-	// Make a new ICMP message
-	m := icmp.Message{
-		Type: ipv4.ICMPTypeEcho, Code: 0,
-		Body: &icmp.Echo{
-			ID: os.Getpid() & 0xffff, Seq: 1, //<< uint(seq), // TODO
-			Data: []byte(""),
-		},
-	}
-	b, err := m.Marshal(nil)
-	if err != nil {
-		return dst, 0, err
-	}
+/*
+Ping over ICMPv6. addr may be a domain, an IPv6 address or a zoned link-local
+address (fe80::1%eth0), iface selects the source address like in Ping.
+*/
+func Ping6(addr, iface string, timeouts ...time.Duration) (*net.IPAddr, time.Duration, error) {
+	return pingFamily(context.Background(), addr, iface, IPFamilyV6, timeouts...)
+}
 
-	// Send it
-	start := time.Now()
-	n, err := c.WriteTo(b, dst)
-	if err != nil {
-		return dst, 0, err
-	} else if n != len(b) {
-		return dst, 0, fmt.Errorf("got %v; want %v", n, len(b))
-	}
+/* Ping6 stopping early when ctx is done, errors like PingContext. */
+func Ping6Context(ctx context.Context, addr, iface string, timeouts ...time.Duration) (*net.IPAddr, time.Duration, error) {
+	return pingFamily(ctx, addr, iface, IPFamilyV6, timeouts...)
+}
 
-	// Wait for a reply
-	reply := make([]byte, 1500)
-	err = c.SetReadDeadline(time.Now().Add(timeout))
-	if err != nil {
-		return dst, 0, err
-	}
-	n, peer, err := c.ReadFrom(reply)
-	if err != nil {
-		return dst, 0, err
+func pingFamily(ctx context.Context, addr, iface string, family IPFamily, timeouts ...time.Duration) (*net.IPAddr, time.Duration, error) {
+	timeout := time.Millisecond * 1000
+	if len(timeouts) != 0 {
+		timeout = timeouts[0]
 	}
-	duration := time.Since(start)
-
-	// Pack it up boys, we're done here
-	rm, err := icmp.ParseMessage(ProtocolICMP, reply[:n])
+	p := &Pinger{Addr: addr, Iface: iface, Family: family, Count: 1, Timeout: timeout}
+	stats, err := p.RunContext(ctx)
 	if err != nil {
-		return dst, 0, err
+		return nil, 0, wrapNetError("ping", addr, err)
 	}
-	switch rm.Type {
-	case ipv4.ICMPTypeEchoReply:
-		return dst, duration, nil
-	default:
-		return dst, 0, fmt.Errorf("got %+v from %v; want echo reply", rm, peer)
+	if stats.Received == 0 {
+		if stats.Err != nil {
+			return stats.Addr, 0, wrapNetError("ping", stats.Addr.String(), stats.Err)
+		}
+		if ctx.Err() != nil {
+			return stats.Addr, 0, &NetError{Op: "ping", Addr: stats.Addr.String(), Err: ctx.Err()}
+		}
+		return stats.Addr, 0, &NetError{Op: "ping", Addr: stats.Addr.String(), Err: os.ErrDeadlineExceeded}
 	}
+	return stats.Addr, stats.RTTs[0], nil
 }
 
 // id is instance in mdns
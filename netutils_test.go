@@ -1,6 +1,7 @@
 package gonetlibs
 
 import (
+	"net"
 	"os"
 	"testing"
 
@@ -55,3 +56,18 @@ func TestNetutils(t *testing.T) {
 		log.Info(ifacecheck, " avaiable to connect to internet.")
 	}
 }
+
+func TestIp6ScopeOf(t *testing.T) {
+	tests := map[string]Ip6Scope{
+		"2001:db8::1":  Ip6ScopeGlobal,
+		"fd00::2":      Ip6ScopeULA,
+		"fe80::1":      Ip6ScopeLinkLocal,
+		"::1":          Ip6ScopeAny,
+		"2606:4700::1": Ip6ScopeGlobal,
+	}
+	for ip, want := range tests {
+		if got := ip6ScopeOf(net.ParseIP(ip)); got != want {
+			t.Errorf("ip6ScopeOf(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PingReply is one echo reply received by a Pinger.
+type PingReply struct {
+	From net.IP
+	Seq  int
+	Size int // ICMP payload bytes
+	TTL  int // -1 when the platform does not report it
+	RTT  time.Duration
+	Dup  bool // the sequence number was already answered
+}
+
+// PingStats sums up a Pinger run, like the last lines of iputils ping.
+type PingStats struct {
+	Addr        *net.IPAddr
+	Transmitted int
+	Received    int
+	Duplicates  int
+	Errors      int     // ICMP errors quoting our requests (unreachable, time exceeded...)
+	Loss        float64 // percent of requests left without reply
+	Min         time.Duration
+	Avg         time.Duration
+	Max         time.Duration
+	Mdev        time.Duration
+	RTTs        []time.Duration
+	Mode        ICMPMode // kind of socket the requests went through, raw or datagram
+	Err         error    // last ICMP error received, nil if none
+}
+
+func (s *PingStats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d packets transmitted, %d received", s.Transmitted, s.Received)
+	if s.Duplicates != 0 {
+		fmt.Fprintf(&b, ", +%d duplicates", s.Duplicates)
+	}
+	if s.Errors != 0 {
+		fmt.Fprintf(&b, ", +%d errors", s.Errors)
+	}
+	fmt.Fprintf(&b, ", %g%% packet loss", s.Loss)
+	if s.Received != 0 {
+		fmt.Fprintf(&b, "\nrtt min/avg/max/mdev = %s/%s/%s/%s", s.Min, s.Avg, s.Max, s.Mdev)
+	}
+	return b.String()
+}
+
+/*
+Pinger sends Count echo requests every Interval and matches the replies by
+identifier and sequence number. Zero Count pings until Deadline or until the
+context given to RunContext is done.
+*/
+type Pinger struct {
+	Addr         string        // domain, IP or zoned link-local IPv6 address (fe80::1%eth0) to ping
+	Iface        string        // interface whose address is used as source, like Ping
+	Family       IPFamily      // any follows the address, domains try IPv4 then IPv6
+	Count        int           // echo requests to send, 0 for no limit
+	Interval     time.Duration // between two requests, default 1s
+	Timeout      time.Duration // replies later than this are lost, and wait for them after the last request, default 1s
+	Deadline     time.Duration // stop after this long whatever the count, 0 for none
+	Size         int           // payload bytes
+	TTL          int           // 0 keeps the system default
+	TOS          int
+	DontFragment bool
+	Mode         ICMPMode        // socket kind, auto falls back to datagram when raw is not permitted
+	OnReply      func(PingReply) // called for every reply, from the reading goroutine
+}
+
+// each Pinger of the process uses its own echo identifier
+var pingerID = uint32(os.Getpid())
+
+// NewPinger returns a Pinger sending 4 requests of 56 bytes, one per second, like iputils ping -c 4.
+func NewPinger(addr string) *Pinger {
+	return &Pinger{Addr: addr, Count: 4, Interval: time.Second, Timeout: time.Second, Size: 56}
+}
+
+// Run pings until Count requests are sent and answered (or timed out), or Deadline expires.
+func (p *Pinger) Run() (*PingStats, error) {
+	return p.RunContext(context.Background())
+}
+
+// RunContext is Run, stopping early when ctx is done.
+func (p *Pinger) RunContext(ctx context.Context) (*PingStats, error) {
+	interval, timeout := p.Interval, p.Timeout
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
+	}
+
+	dst, err := resolvePingTarget(p.Addr, p.Family)
+	if err != nil {
+		return nil, err
+	}
+	v6 := dst.IP.To4() == nil
+	listenAddr, err := ifaceSourceAddr(p.Iface, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := listenICMP(p.Mode, v6, listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if p.TTL > 0 {
+		if err = s.SetTTL(p.TTL); err != nil {
+			return nil, err
+		}
+	}
+	if p.TOS > 0 {
+		if err = s.SetTOS(p.TOS); err != nil {
+			return nil, err
+		}
+	}
+	if p.DontFragment {
+		if err = s.SetDontFragment(true); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		id       = s.id
+		stats    = &PingStats{Addr: dst, Mode: s.mode}
+		mutex    = &sync.Mutex{}
+		sent     = make(map[int]time.Time)
+		answered = make(map[int]bool)
+		allDone  = make(chan struct{})
+		once     sync.Once
+		wg       sync.WaitGroup
+	)
+	if id == 0 {
+		id = int(atomic.AddUint32(&pingerID, 1) & 0xffff)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 65536)
+		for {
+			n, ttl, peer, err := s.ReadFrom(buf)
+			if err != nil {
+				// datagram sockets report some ICMP errors as a failed read
+				if s.mode != ICMPModeDatagram || errors.Is(err, net.ErrClosed) || os.IsTimeout(err) {
+					return
+				}
+				mutex.Lock()
+				stats.Errors++
+				stats.Err = err
+				mutex.Unlock()
+				continue
+			}
+			now := time.Now()
+			echo, err := parseICMPEcho(buf[:n], v6)
+			if err != nil || echo == nil || echo.ID != id {
+				continue
+			}
+			mutex.Lock()
+			start, ok := sent[echo.Seq]
+			if !ok {
+				mutex.Unlock()
+				continue
+			}
+			if !echo.Reply {
+				stats.Errors++
+				stats.Err = fmt.Errorf("got %v from %v; want echo reply", echo.Type, peer)
+				mutex.Unlock()
+				continue
+			}
+			if !peer.Equal(dst.IP) {
+				mutex.Unlock()
+				continue
+			}
+			reply := PingReply{From: peer, Seq: echo.Seq, Size: len(echo.Data), TTL: ttl, RTT: now.Sub(start), Dup: answered[echo.Seq]}
+			if reply.Dup {
+				stats.Duplicates++
+			} else {
+				answered[echo.Seq] = true
+				stats.Received++
+				stats.RTTs = append(stats.RTTs, reply.RTT)
+				if p.Count > 0 && stats.Received >= p.Count {
+					once.Do(func() { close(allDone) })
+				}
+			}
+			mutex.Unlock()
+			if p.OnReply != nil {
+				p.OnReply(reply)
+			}
+		}
+	}()
+
+	payload := make([]byte, max(p.Size, 0))
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+sending:
+	for seq := 0; p.Count <= 0 || seq < p.Count; seq++ {
+		if seq > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				break sending
+			}
+		}
+		b, err := s.echoRequest(id, seq&0xffff, payload)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		mutex.Lock()
+		// the replies of probes older than timeout are lost, and their sequence numbers come back after 65536 probes
+		for old, at := range sent {
+			if now.Sub(at) > timeout {
+				delete(sent, old)
+				delete(answered, old)
+			}
+		}
+		sent[seq&0xffff] = now
+		delete(answered, seq&0xffff)
+		stats.Transmitted++
+		mutex.Unlock()
+		if _, err = s.WriteTo(b, dst); err != nil {
+			mutex.Lock()
+			stats.Errors++
+			stats.Err = err
+			mutex.Unlock()
+		}
+	}
+	select {
+	case <-allDone:
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+	s.Close()
+	wg.Wait()
+
+	stats.compute()
+	return stats, nil
+}
+
+/*
+ifaceSourceAddr returns the address of iface to send from towards dst, "" when
+iface is empty. Link-local destinations use the link-local address of iface and
+get iface as zone when they have none.
+*/
+func ifaceSourceAddr(iface string, dst *net.IPAddr) (string, error) {
+	if len(iface) == 0 {
+		return "", nil
+	}
+	if dst.IP.To4() != nil {
+		return NetGetInterfaceIpv4Addr(iface)
+	}
+	scopes := []Ip6Scope{}
+	if dst.IP.IsLinkLocalUnicast() {
+		scopes = append(scopes, Ip6ScopeLinkLocal)
+		if len(dst.Zone) == 0 {
+			dst.Zone = iface
+		}
+	}
+	return NetGetInterfaceIpv6Addr(iface, scopes...)
+}
+
+/*
+resolvePingTarget turns a Pinger address into the IP to ping. Literal
+addresses keep their zone and must match family, domains are resolved to an
+IPv4 address, or IPv6 for IPFamilyV6 and as a fallback of IPFamilyAny.
+*/
+func resolvePingTarget(addr string, family IPFamily) (*net.IPAddr, error) {
+	host, zone, _ := strings.Cut(addr, "%")
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			if family == IPFamilyV6 {
+				return nil, fmt.Errorf("%s is not an ipv6 address", addr)
+			}
+			return &net.IPAddr{IP: ip4}, nil
+		}
+		if family == IPFamilyV4 {
+			return nil, fmt.Errorf("%s is not an ipv4 address", addr)
+		}
+		return &net.IPAddr{IP: ip, Zone: zone}, nil
+	}
+	var (
+		ip  string
+		err error
+	)
+	switch family {
+	case IPFamilyV4:
+		ip, err = ResolverDomain2Ip4(addr)
+	case IPFamilyV6:
+		ip, err = ResolverDomain2Ip6(addr)
+	default:
+		if ip, err = ResolverDomain2Ip4(addr); err != nil {
+			if ip6, err6 := ResolverDomain2Ip6(addr); err6 == nil {
+				ip, err = ip6, nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &net.IPAddr{IP: net.ParseIP(ip)}, nil
+}
+
+// compute fills the loss and round trip figures from RTTs.
+func (s *PingStats) compute() {
+	if s.Transmitted != 0 {
+		s.Loss = math.Round(float64(s.Transmitted-s.Received)*1000/float64(s.Transmitted)) / 10
+	}
+	if len(s.RTTs) == 0 {
+		return
+	}
+	var sum, sum2 float64
+	s.Min, s.Max = s.RTTs[0], s.RTTs[0]
+	for _, rtt := range s.RTTs {
+		s.Min = min(s.Min, rtt)
+		s.Max = max(s.Max, rtt)
+		sum += float64(rtt)
+		sum2 += float64(rtt) * float64(rtt)
+	}
+	avg := sum / float64(len(s.RTTs))
+	s.Avg = time.Duration(avg)
+	s.Mdev = time.Duration(math.Sqrt(math.Max(sum2/float64(len(s.RTTs))-avg*avg, 0)))
+}
@@ -0,0 +1,106 @@
+package gonetlibs
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPingStatsCompute(t *testing.T) {
+	s := &PingStats{Transmitted: 4, Received: 3, RTTs: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}}
+	s.compute()
+	if s.Loss != 25 {
+		t.Errorf("Loss = %v, want 25", s.Loss)
+	}
+	if s.Min != 10*time.Millisecond || s.Max != 30*time.Millisecond || s.Avg != 20*time.Millisecond {
+		t.Errorf("min/avg/max = %s/%s/%s", s.Min, s.Avg, s.Max)
+	}
+	// population standard deviation of 10, 20, 30
+	if s.Mdev < 8160*time.Microsecond || s.Mdev > 8170*time.Microsecond {
+		t.Errorf("Mdev = %s, want 8.165ms", s.Mdev)
+	}
+}
+
+func TestPingerLoopback(t *testing.T) {
+	p := NewPinger("127.0.0.1")
+	p.Count, p.Interval, p.Size = 3, 20*time.Millisecond, 100
+	replies := 0
+	p.OnReply = func(r PingReply) {
+		if r.Size != 100 {
+			t.Errorf("reply %d has %d bytes", r.Seq, r.Size)
+		}
+		replies++
+	}
+	stats, err := p.Run()
+	if errors.Is(err, os.ErrPermission) {
+		t.Skip("no permission to open an ICMP socket")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Transmitted != 3 || stats.Received != 3 || stats.Loss != 0 || replies != 3 {
+		t.Errorf("%s", stats)
+	}
+}
+
+func TestPingerModes(t *testing.T) {
+	for _, mode := range []ICMPMode{ICMPModeRaw, ICMPModeDatagram, ICMPModeAuto} {
+		p := &Pinger{Addr: "127.0.0.1", Count: 2, Interval: 20 * time.Millisecond, Mode: mode}
+		stats, err := p.Run()
+		if errors.Is(err, os.ErrPermission) {
+			t.Logf("%s: no permission to open the socket", mode)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if stats.Received != 2 {
+			t.Errorf("%s: %s", mode, stats)
+		}
+		if stats.Mode == ICMPModeAuto || (mode != ICMPModeAuto && stats.Mode != mode) {
+			t.Errorf("%s: socket mode %s", mode, stats.Mode)
+		}
+	}
+}
+
+func TestPingerLoopback6(t *testing.T) {
+	p := &Pinger{Addr: "::1", Iface: "lo", Count: 2, Interval: 20 * time.Millisecond, Size: 64}
+	stats, err := p.Run()
+	if errors.Is(err, os.ErrPermission) {
+		t.Skip("no permission to open an ICMPv6 socket")
+	}
+	if err != nil {
+		t.Skip(err)
+	}
+	if stats.Received != 2 || stats.Addr.String() != "::1" {
+		t.Errorf("%s", stats)
+	}
+}
+
+func TestResolvePingTarget(t *testing.T) {
+	tests := []struct {
+		addr   string
+		family IPFamily
+		want   string
+	}{
+		{"192.0.2.1", IPFamilyAny, "192.0.2.1"},
+		{"::ffff:192.0.2.1", IPFamilyV4, "192.0.2.1"},
+		{"2001:db8::1", IPFamilyAny, "2001:db8::1"},
+		{"fe80::1%eth0", IPFamilyV6, "fe80::1%eth0"},
+		{"192.0.2.1", IPFamilyV6, ""},
+		{"2001:db8::1", IPFamilyV4, ""},
+	}
+	for _, test := range tests {
+		dst, err := resolvePingTarget(test.addr, test.family)
+		if len(test.want) == 0 {
+			if err == nil {
+				t.Errorf("resolvePingTarget(%s, %d) = %v, want an error", test.addr, test.family, dst)
+			}
+			continue
+		}
+		if err != nil || dst.String() != test.want {
+			t.Errorf("resolvePingTarget(%s, %d) = %v, %v, want %s", test.addr, test.family, dst, err, test.want)
+		}
+	}
+}
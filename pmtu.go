@@ -0,0 +1,359 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// PMTUOptions tunes NetPathMTU. A nil *PMTUOptions uses the defaults.
+type PMTUOptions struct {
+	Iface   string        // interface whose address is used as source, like Ping
+	Family  IPFamily      // any follows the address, domains try IPv4 then IPv6
+	UDP     bool          // probe with udp datagrams to Port, the destination must answer port unreachable. Needs CAP_NET_RAW.
+	Port    int           // destination port of the first udp probe, one more per probe, default 33434
+	Min     int           // smallest packet size tried, default 576 (1280 for IPv6)
+	Max     int           // largest packet size tried, default the interface MTU
+	Timeout time.Duration // wait for an answer to each probe, default 1s
+	Retries int           // probes of one size before it is considered dropped, default 2
+	Mode    ICMPMode      // socket kind of icmp probes, like Pinger
+}
+
+// PMTUResult is the path MTU found by NetPathMTU. Sizes include the IP header.
+type PMTUResult struct {
+	Dst        *net.IPAddr
+	MTU        int    // largest packet that reached the destination
+	Iface      string // outgoing interface
+	IfaceMTU   int    // MTU of Iface, 0 when unknown
+	Reported   int    // lowest next-hop MTU in fragmentation needed / packet too big errors, 0 if none came
+	ReportedBy net.IP // router that sent Reported
+	Probes     int
+}
+
+// Reduced tells that the path carries smaller packets than the outgoing interface.
+func (r *PMTUResult) Reduced() bool {
+	return r.IfaceMTU > 0 && r.MTU < r.IfaceMTU
+}
+
+/*
+Blackhole tells that the path is reduced but no router said so: large packets
+are silently dropped, like on many VPN and PPPoE links, and TCP sessions stall
+unless their MSS is clamped.
+*/
+func (r *PMTUResult) Blackhole() bool {
+	return r.Reduced() && r.Reported == 0
+}
+
+func (r *PMTUResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "path mtu to %s is %d", r.Dst, r.MTU)
+	if r.IfaceMTU > 0 {
+		fmt.Fprintf(&b, ", %s mtu %d", r.Iface, r.IfaceMTU)
+	}
+	if r.Reported > 0 {
+		fmt.Fprintf(&b, ", %d reported by %s", r.Reported, r.ReportedBy)
+	} else if r.Blackhole() {
+		b.WriteString(", large packets silently dropped")
+	}
+	return b.String()
+}
+
+// pmtuProber sends one DF packet of size bytes and tells whether it got through.
+type pmtuProber interface {
+	// probe returns the next-hop MTU and its sender when a router says the packet is too big
+	probe(size, seq int, timeout time.Duration) (ok bool, mtu int, from net.IP, err error)
+	Close() error
+}
+
+/*
+NetPathMTU binary-searches the largest packet that reaches addr without
+fragmentation. Probes have DF set, fragmentation needed (packet too big on
+IPv6) answers jump straight to the reported MTU, lost probes halve the range.
+Compare the result with the interface MTU through Reduced and Blackhole.
+*/
+func NetPathMTU(ctx context.Context, addr string, opts *PMTUOptions) (*PMTUResult, error) {
+	o := PMTUOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Port <= 0 {
+		o.Port = 33434
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Second
+	}
+	if o.Retries <= 0 {
+		o.Retries = 2
+	}
+
+	dst, err := resolvePingTarget(addr, o.Family)
+	if err != nil {
+		return nil, err
+	}
+	v6 := dst.IP.To4() == nil
+	listenAddr, err := ifaceSourceAddr(o.Iface, dst)
+	if err != nil {
+		return nil, err
+	}
+	result := &PMTUResult{Dst: dst, Iface: o.Iface}
+	if len(result.Iface) == 0 {
+		result.Iface, _, _ = NetRouteIface(dst.String())
+	}
+	if ief, err := net.InterfaceByName(result.Iface); err == nil {
+		result.IfaceMTU = ief.MTU
+	}
+	hdr := ipv4.HeaderLen
+	if o.Min <= 0 {
+		o.Min = 576
+		if v6 {
+			o.Min = 1280
+		}
+	}
+	if v6 {
+		hdr = ipv6.HeaderLen
+	}
+	if o.Max <= 0 {
+		o.Max = result.IfaceMTU
+		if o.Max <= 0 {
+			o.Max = 1500
+		}
+	}
+	// an IP packet can not be bigger, whatever the MTU of loopback says
+	o.Max = min(o.Max, 65535)
+	if o.Min < hdr+8 || o.Min > o.Max {
+		return nil, fmt.Errorf("invalid mtu range %d-%d", o.Min, o.Max)
+	}
+
+	var prober pmtuProber
+	if o.UDP {
+		prober, err = newPMTUUDP(dst, listenAddr, o.Port)
+	} else {
+		prober, err = newPMTUICMP(dst, listenAddr, o.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer prober.Close()
+
+	// lo is the largest size known to get through, hi the smallest known to be too big
+	lo, hi, size := o.Min-1, o.Max+1, o.Max
+	for hi-lo > 1 && ctx.Err() == nil {
+		ok, mtu := false, 0
+		var from net.IP
+		for try := 0; try < o.Retries && !ok && mtu == 0 && ctx.Err() == nil; try++ {
+			result.Probes++
+			if ok, mtu, from, err = prober.probe(size, result.Probes, o.Timeout); err != nil {
+				return nil, err
+			}
+		}
+		if ok {
+			lo = size
+		} else {
+			hi = size
+		}
+		if mtu > 0 && (result.Reported == 0 || mtu < result.Reported) {
+			result.Reported, result.ReportedBy = mtu, from
+		}
+		size = (lo + hi) / 2
+		if !ok && mtu > lo && mtu < hi {
+			size = mtu
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if lo < o.Min {
+		return nil, fmt.Errorf("no probe of %d bytes or more reached %s", o.Min, dst)
+	}
+	result.MTU = lo
+	return result, nil
+}
+
+// isTooBig tells whether an ICMP error is fragmentation needed or packet too big.
+func isTooBig(e *icmpError) bool {
+	return e.Type == ipv6.ICMPTypePacketTooBig || (e.Type == ipv4.ICMPTypeDestinationUnreachable && e.Code == 4)
+}
+
+// pmtuICMP probes with echo requests.
+type pmtuICMP struct {
+	s   *icmpSocket
+	dst *net.IPAddr
+	id  int
+	hdr int
+	buf []byte
+}
+
+func newPMTUICMP(dst *net.IPAddr, laddr string, mode ICMPMode) (*pmtuICMP, error) {
+	v6 := dst.IP.To4() == nil
+	s, err := listenICMP(mode, v6, laddr)
+	if err != nil {
+		return nil, err
+	}
+	if err = setPMTUProbe(s.conn, v6); err != nil {
+		s.Close()
+		return nil, err
+	}
+	p := &pmtuICMP{s: s, dst: dst, id: s.id, hdr: ipv4.HeaderLen, buf: make([]byte, 65536)}
+	if v6 {
+		p.hdr = ipv6.HeaderLen
+	}
+	if p.id == 0 {
+		p.id = int(atomic.AddUint32(&pingerID, 1) & 0xffff)
+	}
+	return p, nil
+}
+
+func (p *pmtuICMP) probe(size, seq int, timeout time.Duration) (bool, int, net.IP, error) {
+	seq &= 0xffff
+	b, err := p.s.echoRequest(p.id, seq, make([]byte, size-p.hdr-8))
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if _, err = p.s.WriteTo(b, p.dst); err != nil {
+		// bigger than the MTU of the outgoing interface
+		if errors.Is(err, syscall.EMSGSIZE) {
+			return false, 0, nil, nil
+		}
+		return false, 0, nil, err
+	}
+	p.s.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, _, peer, err := p.s.ReadFrom(p.buf)
+		if err != nil {
+			if os.IsTimeout(err) {
+				return false, 0, nil, nil
+			}
+			// datagram sockets report some ICMP errors as a failed read, fragmentation needed as EMSGSIZE
+			if errors.Is(err, syscall.EMSGSIZE) {
+				return false, 0, nil, nil
+			}
+			if p.s.mode == ICMPModeDatagram {
+				continue
+			}
+			return false, 0, nil, err
+		}
+		echo, err := parseICMPEcho(p.buf[:n], p.s.v6())
+		if err != nil || echo == nil || echo.ID != p.id || echo.Seq != seq {
+			continue
+		}
+		if echo.Reply {
+			if peer.Equal(p.dst.IP) {
+				return true, 0, nil, nil
+			}
+			continue
+		}
+		if e, _ := parseICMPError(p.buf[:n], p.s.v6()); e != nil && isTooBig(e) {
+			return false, e.MTU, peer, nil
+		}
+		return false, 0, nil, fmt.Errorf("got %v from %v; want echo reply", echo.Type, peer)
+	}
+}
+
+func (p *pmtuICMP) Close() error {
+	return p.s.Close()
+}
+
+// pmtuUDP sends udp datagrams and reads the ICMP errors they cause on a raw socket.
+type pmtuUDP struct {
+	s     *icmpSocket
+	conn  *net.UDPConn
+	dst   *net.IPAddr
+	lport int
+	port  int
+	hdr   int
+	buf   []byte
+}
+
+func newPMTUUDP(dst *net.IPAddr, laddr string, port int) (*pmtuUDP, error) {
+	v6 := dst.IP.To4() == nil
+	s, err := listenICMP(ICMPModeRaw, v6, laddr)
+	if err != nil {
+		return nil, err
+	}
+	network, local := "udp4", &net.UDPAddr{}
+	if v6 {
+		network = "udp6"
+	}
+	if len(laddr) != 0 {
+		ip, zone, _ := strings.Cut(laddr, "%")
+		local = &net.UDPAddr{IP: net.ParseIP(ip), Zone: zone}
+	}
+	conn, err := net.ListenUDP(network, local)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err = setPMTUProbe(conn, v6); err != nil {
+		conn.Close()
+		s.Close()
+		return nil, err
+	}
+	p := &pmtuUDP{s: s, conn: conn, dst: dst, lport: conn.LocalAddr().(*net.UDPAddr).Port, port: port, hdr: ipv4.HeaderLen, buf: make([]byte, 1500)}
+	if v6 {
+		p.hdr = ipv6.HeaderLen
+	}
+	return p, nil
+}
+
+func (p *pmtuUDP) probe(size, seq int, timeout time.Duration) (bool, int, net.IP, error) {
+	// a new port per probe, so a late answer is not taken for the one of another size
+	port := (p.port+seq-2)%65535 + 1
+	_, err := p.conn.WriteToUDP(make([]byte, size-p.hdr-8), &net.UDPAddr{IP: p.dst.IP, Port: port, Zone: p.dst.Zone})
+	if err != nil {
+		if errors.Is(err, syscall.EMSGSIZE) {
+			return false, 0, nil, nil
+		}
+		return false, 0, nil, err
+	}
+	p.s.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, _, peer, err := p.s.ReadFrom(p.buf)
+		if err != nil {
+			if os.IsTimeout(err) {
+				return false, 0, nil, nil
+			}
+			return false, 0, nil, err
+		}
+		e, err := parseICMPError(p.buf[:n], p.s.v6())
+		if err != nil || e == nil || e.Proto != syscall.IPPROTO_UDP || !e.Dst.Equal(p.dst.IP) {
+			continue
+		}
+		// the error quotes the start of the datagram only, its size is lost: match the ports
+		if src, dst := quotedPorts(e); src != p.lport || dst != port {
+			continue
+		}
+		if isTooBig(e) {
+			return false, e.MTU, peer, nil
+		}
+		unreach := isUnreachable(e.Type)
+		if unreach && peer.Equal(p.dst.IP) && e.Code == portUnreachableCode(p.s.v6()) {
+			return true, 0, nil, nil
+		}
+		if unreach {
+			return false, 0, nil, &UnreachableError{From: peer, V6: p.s.v6(), Code: e.Code}
+		}
+		return false, 0, nil, fmt.Errorf("got %v from %v; want port unreachable", e.Type, peer)
+	}
+}
+
+func (p *pmtuUDP) Close() error {
+	p.conn.Close()
+	return p.s.Close()
+}
+
+// portUnreachableCode is the destination unreachable code of a closed udp port.
+func portUnreachableCode(v6 bool) int {
+	if v6 {
+		return 4
+	}
+	return 3
+}
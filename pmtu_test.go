@@ -0,0 +1,41 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestPMTUResult(t *testing.T) {
+	r := &PMTUResult{Dst: &net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}, MTU: 1400, Iface: "ppp0", IfaceMTU: 1492}
+	if !r.Reduced() || !r.Blackhole() {
+		t.Errorf("%s: reduced %v, blackhole %v", r, r.Reduced(), r.Blackhole())
+	}
+	r.Reported, r.ReportedBy = 1400, net.IPv4(192, 0, 2, 254)
+	if r.Blackhole() {
+		t.Errorf("%s: reported mtu taken for a blackhole", r)
+	}
+	if s, want := r.String(), "path mtu to 192.0.2.1 is 1400, ppp0 mtu 1492, 1400 reported by 192.0.2.254"; s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+}
+
+func TestNetPathMTULoopback(t *testing.T) {
+	for _, udp := range []bool{false, true} {
+		r, err := NetPathMTU(context.Background(), "127.0.0.1", &PMTUOptions{UDP: udp, Iface: "lo", Max: 9000})
+		if errors.Is(err, os.ErrPermission) {
+			t.Skip("no permission to open an ICMP socket")
+		}
+		if err != nil {
+			t.Fatalf("udp %v: %v", udp, err)
+		}
+		if r.MTU != 9000 || r.Probes != 1 || r.IfaceMTU == 0 {
+			t.Errorf("udp %v: %s after %d probes", udp, r, r.Probes)
+		}
+	}
+	if _, err := NetPathMTU(context.Background(), "127.0.0.1", &PMTUOptions{Min: 1500, Max: 1000}); err == nil {
+		t.Error("invalid range accepted")
+	}
+}
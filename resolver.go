@@ -0,0 +1,552 @@
+package gonetlibs
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mannk98/gonetlibs/gcurl"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+// Upstream is a DNS server queried by a Resolver.
+type Upstream struct {
+	Proto  string // udp (retried over tcp when the answer is truncated), tcp, tls or https
+	Addr   string // host:port, or the url of https
+	Method string // http method of https, GET (default) or POST
+
+	ServerName string         // name sent (SNI) and verified by tls, default the host of Addr
+	SPKIPins   []string       // keys accepted from tls instead of verifying the certificate, see SPKIPin
+	RootCAs    *x509.CertPool // authorities verifying tls, default the system ones
+}
+
+func (u Upstream) String() string {
+	switch {
+	case u.Proto == "https":
+		return u.Addr
+	case u.Proto == "tls" && len(u.ServerName) != 0:
+		return u.Proto + "://" + u.Addr + "#" + u.ServerName
+	}
+	return u.Proto + "://" + u.Addr
+}
+
+/*
+ParseUpstream parses an upstream written as host[:port] for udp,
+proto://host[:port], tls://host[:port][#servername], or the url of a
+DNS-over-HTTPS server. The port defaults to 53, 853 for tls, and the path of the
+url to /dns-query.
+*/
+func ParseUpstream(s string) (Upstream, error) {
+	proto, addr, found := strings.Cut(s, "://")
+	if !found {
+		proto, addr = "udp", s
+	}
+	switch proto {
+	case "https":
+		target, err := url.Parse(s)
+		if err != nil || len(target.Host) == 0 {
+			return Upstream{}, fmt.Errorf("invalid dns upstream %q", s)
+		}
+		if len(target.Path) == 0 {
+			target.Path = "/dns-query"
+		}
+		return Upstream{Proto: proto, Addr: target.String()}, nil
+	case "udp", "tcp", "tls":
+	default:
+		return Upstream{}, fmt.Errorf("unsupported dns upstream %q", s)
+	}
+	u := Upstream{Proto: proto}
+	port := "53"
+	if proto == "tls" {
+		addr, u.ServerName, _ = strings.Cut(addr, "#")
+		port = "853"
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+	}
+	if len(addr) <= len(":"+port) {
+		return Upstream{}, fmt.Errorf("invalid dns upstream %q", s)
+	}
+	u.Addr = addr
+	return u, nil
+}
+
+/*
+Resolver queries its upstreams in parallel with staggered starts: the first one
+at once, the next one Stagger later or as soon as the previous one failed. The
+first good answer wins and the whole lookup shares one Timeout.
+*/
+type Resolver struct {
+	Upstreams      []Upstream
+	Stagger        time.Duration // delay before the next upstream is queried too, default 200ms
+	Timeout        time.Duration // deadline of a whole lookup, default 3s
+	Iface          string        // interface whose address is used as source, like ServerIsLive
+	SystemFallback bool          // LookupHost also asks the system resolver when the upstreams fail or are slow
+	Debug          bool          // log the upstreams that fail, like the debugflag of ResolverDomain
+	Cache          *DNSCache     // answers kept between lookups, none when nil
+	// Dial opens the connections to the upstreams, tls ones included, a net.Dialer bound to Iface when nil
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// HTTP configures the client of the https upstreams (proxy, tls, timeouts), read at
+	// the first https query. Dial is used unless the proxy is socks5, and without
+	// ProxyURL the proxy comes from the environment.
+	HTTP *gcurl.ConnectionOption
+
+	httpMutex  sync.Mutex
+	httpClient *http.Client
+	tlsMutex   sync.Mutex
+	tlsConns   map[string]*dotConn // kept per upstream
+}
+
+/*
+DefaultResolver is used by ResolverDomain and the helpers resolving domains:
+the public DNS servers, then the system resolver. It caches the answers so that
+the connectivity checks repeated every few seconds do not query the upstreams.
+*/
+var DefaultResolver = &Resolver{Upstreams: mustParseUpstreams(dnslist...), SystemFallback: true, Cache: NewDNSCache()}
+
+// NewResolver returns a resolver of upstreams, see ParseUpstream.
+func NewResolver(upstreams ...string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, s := range upstreams {
+		u, err := ParseUpstream(s)
+		if err != nil {
+			return nil, err
+		}
+		r.Upstreams = append(r.Upstreams, u)
+	}
+	return r, nil
+}
+
+func mustParseUpstreams(upstreams ...string) []Upstream {
+	r, err := NewResolver(upstreams...)
+	if err != nil {
+		panic(err)
+	}
+	return r.Upstreams
+}
+
+func (r *Resolver) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+	return 3 * time.Second
+}
+
+func (r *Resolver) stagger() time.Duration {
+	if r.Stagger > 0 {
+		return r.Stagger
+	}
+	return 200 * time.Millisecond
+}
+
+/*
+Exchange sends m to the upstreams and returns the first good answer: NOERROR or
+NXDOMAIN. SERVFAIL, REFUSED and network errors move on to the next upstream.
+*/
+func (r *Resolver) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	return r.exchange(ctx, m, r.Debug)
+}
+
+func (r *Resolver) exchange(ctx context.Context, m *dns.Msg, debug bool) (*dns.Msg, error) {
+	if r.Cache != nil {
+		return r.Cache.exchange(ctx, m, func(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+			return r.exchangeUpstreams(ctx, m, debug)
+		})
+	}
+	return r.exchangeUpstreams(ctx, m, debug)
+}
+
+// exchangeUpstreams races the upstreams, see Resolver.
+func (r *Resolver) exchangeUpstreams(ctx context.Context, m *dns.Msg, debug bool) (*dns.Msg, error) {
+	if len(r.Upstreams) == 0 {
+		return nil, &NetError{Op: "lookup", Addr: questionName(m), Kind: ErrDNS, Err: errors.New("no dns upstream")}
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout())
+	defer cancel()
+
+	type result struct {
+		u     Upstream
+		reply *dns.Msg
+		err   error
+	}
+	var (
+		results  = make(chan result, len(r.Upstreams))
+		next     = 0
+		inflight = 0
+		lastErr  error
+	)
+	start := func() {
+		u := r.Upstreams[next]
+		next++
+		inflight++
+		go func() {
+			reply, err := r.exchangeUpstream(ctx, u, m.Copy())
+			results <- result{u, reply, err}
+		}()
+	}
+	start()
+	timer := time.NewTimer(r.stagger())
+	defer timer.Stop()
+	for inflight > 0 {
+		select {
+		case res := <-results:
+			inflight--
+			if res.err == nil {
+				return res.reply, nil
+			}
+			lastErr = res.err
+			if debug {
+				log.Errorf("Can not used dns server %s for finding %s: %v", res.u, questionName(m), res.err)
+			}
+			if next < len(r.Upstreams) {
+				start()
+				timer.Reset(r.stagger())
+			}
+		case <-timer.C:
+			if next < len(r.Upstreams) {
+				start()
+				timer.Reset(r.stagger())
+			}
+		case <-ctx.Done():
+			return nil, &NetError{Op: "lookup", Addr: questionName(m), Kind: ErrDNS, Err: ctx.Err()}
+		}
+	}
+	return nil, &NetError{Op: "lookup", Addr: questionName(m), Kind: ErrDNS, Err: lastErr}
+}
+
+// exchangeUpstream asks one upstream, a truncated udp answer is asked again over tcp.
+func (r *Resolver) exchangeUpstream(ctx context.Context, u Upstream, m *dns.Msg) (reply *dns.Msg, err error) {
+	switch u.Proto {
+	case "https":
+		reply, err = r.exchangeHTTPS(ctx, u, m)
+	case "tls":
+		reply, err = r.exchangeTLS(ctx, u, m)
+	default:
+		reply, err = r.exchangeConn(ctx, u.Proto, u.Addr, m)
+		if err == nil && reply.Truncated && u.Proto == "udp" {
+			reply, err = r.exchangeConn(ctx, "tcp", u.Addr, m)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reply.Rcode != dns.RcodeSuccess && reply.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s answered %s", u, dns.RcodeToString[reply.Rcode])
+	}
+	return reply, nil
+}
+
+func (r *Resolver) exchangeConn(ctx context.Context, network, addr string, m *dns.Msg) (*dns.Msg, error) {
+	conn, err := r.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return exchangeOn(ctx, conn, m)
+}
+
+// exchangeOn writes m on conn and reads its answer, streams are framed by dns.Conn.
+func exchangeOn(ctx context.Context, conn net.Conn, m *dns.Msg) (*dns.Msg, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// the deadline alone does not follow a cancellation
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	co := &dns.Conn{Conn: conn}
+	if err := co.WriteMsg(m); err != nil {
+		return nil, err
+	}
+	for {
+		reply, err := co.ReadMsg()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if reply.Id == m.Id {
+			return reply, nil
+		}
+	}
+}
+
+const dohMediaType = "application/dns-message"
+
+// exchangeHTTPS sends m to a DNS-over-HTTPS server (RFC 8484).
+func (r *Resolver) exchangeHTTPS(ctx context.Context, u Upstream, m *dns.Msg) (*dns.Msg, error) {
+	client, err := r.dohClient()
+	if err != nil {
+		return nil, err
+	}
+	// the id is 0 so that http caches see the same request for the same question
+	q := m.Copy()
+	q.Id = 0
+	wire, err := q.Pack()
+	if err != nil {
+		return nil, err
+	}
+	var req *http.Request
+	if strings.EqualFold(u.Method, http.MethodPost) {
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, u.Addr, bytes.NewReader(wire)); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", dohMediaType)
+	} else {
+		target, err := url.Parse(u.Addr)
+		if err != nil {
+			return nil, err
+		}
+		query := target.Query()
+		query.Set("dns", base64.RawURLEncoding.EncodeToString(wire))
+		target.RawQuery = query.Encode()
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil); err != nil {
+			return nil, err
+		}
+	}
+	req.Header.Set("Accept", dohMediaType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", u, resp.Status)
+	}
+	if mediatype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediatype != dohMediaType {
+		return nil, fmt.Errorf("%s answered %q content", u, mediatype)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+	reply := new(dns.Msg)
+	if err = reply.Unpack(body); err != nil {
+		return nil, err
+	}
+	reply.Id = m.Id
+	return reply, nil
+}
+
+// dohClient builds the http client of the https upstreams once, so their connections are kept alive.
+func (r *Resolver) dohClient() (*http.Client, error) {
+	r.httpMutex.Lock()
+	defer r.httpMutex.Unlock()
+	if r.httpClient != nil {
+		return r.httpClient, nil
+	}
+	var option *gcurl.ConnectionOption
+	if r.HTTP != nil {
+		// gcurl fills the defaults in the option
+		copied := *r.HTTP
+		option = &copied
+	}
+	client, err := gcurl.NewClient(option)
+	if err != nil {
+		return nil, err
+	}
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		proxy := ""
+		if option != nil {
+			proxy = option.ProxyURL
+		}
+		// gcurl only sets the proxy of the environment without option
+		if len(proxy) == 0 {
+			transport.Proxy = http.ProxyFromEnvironment
+		}
+		// a socks5 proxy is reached through transport.Dial, which DialContext would bypass
+		if proxyURL, err := url.Parse(proxy); err != nil || proxyURL.Scheme != "socks5" {
+			// the timeouts of gcurl without option
+			d := net.Dialer{Timeout: 30 * time.Second, KeepAlive: 15 * time.Second}
+			if option != nil {
+				d.Timeout, d.KeepAlive = option.DialTimeout, option.DialKeepAlive
+			}
+			transport.Dial = nil
+			transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+				return r.dialWith(ctx, d, network, address)
+			}
+		}
+	}
+	r.httpClient = client
+	return client, nil
+}
+
+func (r *Resolver) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	return r.dialWith(ctx, net.Dialer{}, network, address)
+}
+
+// dialWith dials address with d from the address of Iface, or through the Dial hook within the timeout of d.
+func (r *Resolver) dialWith(ctx context.Context, d net.Dialer, network, address string) (net.Conn, error) {
+	if r.Dial != nil {
+		if d.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+			defer cancel()
+		}
+		return r.Dial(ctx, network, address)
+	}
+	if len(r.Iface) != 0 {
+		host, _, _ := net.SplitHostPort(address)
+		var (
+			localip string
+			err     error
+		)
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			localip, err = NetGetInterfaceIpv6Addr(r.Iface)
+		} else {
+			localip, err = NetGetInterfaceIpv4Addr(r.Iface)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(network, "udp") {
+			d.LocalAddr, err = net.ResolveUDPAddr(network, net.JoinHostPort(localip, "0"))
+		} else {
+			d.LocalAddr, err = net.ResolveTCPAddr(network, net.JoinHostPort(localip, "0"))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d.DialContext(ctx, network, address)
+}
+
+/*
+LookupHost returns the IPv4 then IPv6 addresses of host. With SystemFallback the
+system resolver is asked too, once the upstreams failed or half of the Timeout
+passed, so names only known on the local network still resolve.
+*/
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r.lookupHost(ctx, host, r.Debug)
+}
+
+func (r *Resolver) lookupHost(ctx context.Context, host string, debug bool) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout())
+	defer cancel()
+
+	type result struct {
+		addrs []string
+		err   error
+	}
+	results := make(chan result, 2)
+	go func() {
+		addrs, err := r.lookupUpstreams(ctx, host, debug)
+		results <- result{addrs, err}
+	}()
+	var fallback <-chan time.Time
+	if r.SystemFallback {
+		timer := time.NewTimer(r.timeout() / 2)
+		defer timer.Stop()
+		fallback = timer.C
+	}
+	system := func() {
+		fallback = nil
+		go func() {
+			addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+			results <- result{addrs, err}
+		}()
+	}
+	var firstErr error
+	for pending := 1; pending > 0; {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.addrs, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if fallback != nil {
+				system()
+				pending++
+			}
+		case <-fallback:
+			system()
+			pending++
+		}
+	}
+	var nerr *NetError
+	if errors.As(firstErr, &nerr) {
+		return nil, firstErr
+	}
+	return nil, &NetError{Op: "lookup", Addr: host, Kind: ErrDNS, Err: firstErr}
+}
+
+// lookupUpstreams asks the A and AAAA records of host at once.
+func (r *Resolver) lookupUpstreams(ctx context.Context, host string, debug bool) ([]string, error) {
+	type result struct {
+		addrs []string
+		err   error
+	}
+	qtypes := []uint16{dns.TypeA, dns.TypeAAAA}
+	results := make([]chan result, len(qtypes))
+	for i, qtype := range qtypes {
+		results[i] = make(chan result, 1)
+		go func(qtype uint16, ch chan<- result) {
+			m := new(dns.Msg)
+			m.SetQuestion(dns.Fqdn(host), qtype)
+			m.SetEdns0(1232, false)
+			reply, err := r.exchange(ctx, m, debug)
+			if err != nil {
+				ch <- result{nil, err}
+				return
+			}
+			if reply.Rcode == dns.RcodeNameError {
+				ch <- result{nil, errNoSuchHost(host)}
+				return
+			}
+			var addrs []string
+			for _, rr := range reply.Answer {
+				switch rr := rr.(type) {
+				case *dns.A:
+					addrs = append(addrs, rr.A.String())
+				case *dns.AAAA:
+					addrs = append(addrs, rr.AAAA.String())
+				}
+			}
+			ch <- result{addrs, nil}
+		}(qtype, results[i])
+	}
+	var (
+		addrs []string
+		err   error
+	)
+	for _, ch := range results {
+		res := <-ch
+		addrs = append(addrs, res.addrs...)
+		if res.err != nil {
+			err = res.err
+		}
+	}
+	if len(addrs) != 0 {
+		return addrs, nil
+	}
+	if err == nil {
+		err = errNoSuchHost(host)
+	}
+	return nil, err
+}
+
+// questionName is the name asked by m, for the errors.
+func questionName(m *dns.Msg) string {
+	if len(m.Question) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(m.Question[0].Name, ".")
+}
@@ -0,0 +1,355 @@
+package gonetlibs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mannk98/gonetlibs/gcurl"
+	"github.com/miekg/dns"
+)
+
+// startDNSServer serves handler on a local udp and tcp port and returns its address.
+func startDNSServer(t *testing.T, handler dns.HandlerFunc) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp4", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Fatal(err)
+	}
+	for _, server := range []*dns.Server{{PacketConn: pc, Handler: handler}, {Listener: l, Handler: handler}} {
+		go server.ActivateAndServe()
+		t.Cleanup(func() { server.Shutdown() })
+	}
+	return pc.LocalAddr().String()
+}
+
+// answerA answers 192.0.2.<last> to A questions after delay.
+func answerA(last byte, delay time.Duration) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(delay)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.IPv4(192, 0, 2, last),
+			})
+		}
+		w.WriteMsg(m)
+	}
+}
+
+func TestParseUpstream(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Upstream
+		ok   bool
+	}{
+		{"8.8.8.8", Upstream{Proto: "udp", Addr: "8.8.8.8:53"}, true},
+		{"tcp://1.1.1.1", Upstream{Proto: "tcp", Addr: "1.1.1.1:53"}, true},
+		{"127.0.0.1:5353", Upstream{Proto: "udp", Addr: "127.0.0.1:5353"}, true},
+		{"[2001:4860:4860::8888]", Upstream{Proto: "udp", Addr: "[2001:4860:4860::8888]:53"}, true},
+		{"https://dns.example/dns-query", Upstream{Proto: "https", Addr: "https://dns.example/dns-query"}, true},
+		{"https://dns.example", Upstream{Proto: "https", Addr: "https://dns.example/dns-query"}, true},
+		{"tls://1.1.1.1#cloudflare-dns.com", Upstream{Proto: "tls", Addr: "1.1.1.1:853", ServerName: "cloudflare-dns.com"}, true},
+		{"tls://[::1]:8853", Upstream{Proto: "tls", Addr: "[::1]:8853"}, true},
+		{"quic://8.8.8.8", Upstream{}, false},
+		{"", Upstream{}, false},
+	}
+	for _, tt := range tests {
+		u, err := ParseUpstream(tt.in)
+		if (err == nil) != tt.ok || !reflect.DeepEqual(u, tt.want) {
+			t.Errorf("ParseUpstream(%q) = %v, %v", tt.in, u, err)
+		}
+	}
+}
+
+func TestResolverStagger(t *testing.T) {
+	servfail := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+	})
+	slow := startDNSServer(t, answerA(1, 2*time.Second))
+	fast := startDNSServer(t, answerA(2, 0))
+
+	// the failing server hands over at once, the slow one is raced after Stagger
+	r, err := NewResolver(servfail, slow, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Stagger = 50 * time.Millisecond
+	start := time.Now()
+	addrs, err := r.LookupHost(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "192.0.2.2" {
+		t.Errorf("addrs %v", addrs)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %v for the slow upstream", elapsed)
+	}
+}
+
+func TestResolverTruncated(t *testing.T) {
+	addr := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Truncated = true
+			w.WriteMsg(m)
+			return
+		}
+		answerA(3, 0)(w, r)
+	})
+	r, err := NewResolver(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := r.LookupHost(context.Background(), "example.test")
+	if err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.3" {
+		t.Errorf("addrs %v, err %v", addrs, err)
+	}
+}
+
+func TestResolverDeadline(t *testing.T) {
+	slow := startDNSServer(t, answerA(1, 2*time.Second))
+	nxdomain := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeNameError)
+		w.WriteMsg(m)
+	})
+
+	r, err := NewResolver(slow, slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Timeout = 200 * time.Millisecond
+	start := time.Now()
+	_, err = r.LookupHost(context.Background(), "example.test")
+	if !errors.Is(err, ErrDNS) || !errors.Is(err, ErrTimeout) {
+		t.Errorf("err %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("lookup took %v", elapsed)
+	}
+
+	r, err = NewResolver(nxdomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.LookupHost(context.Background(), "missing.test")
+	var derr *net.DNSError
+	if !errors.Is(err, ErrDNS) || !errors.As(err, &derr) || !derr.IsNotFound {
+		t.Errorf("err %v", err)
+	}
+}
+
+func TestResolverDoH(t *testing.T) {
+	var (
+		mutex   sync.Mutex
+		methods []string
+	)
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wire []byte
+		var err error
+		if r.Method == http.MethodPost {
+			if r.Header.Get("Content-Type") != dohMediaType {
+				http.Error(w, "bad content type", http.StatusUnsupportedMediaType)
+				return
+			}
+			wire, err = io.ReadAll(r.Body)
+		} else {
+			wire, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		}
+		q := new(dns.Msg)
+		if err == nil {
+			err = q.Unpack(wire)
+		}
+		if err != nil || q.Id != 0 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		methods = append(methods, r.Method)
+		mutex.Unlock()
+		rec := httptest.NewRecorder()
+		answerA(4, 0)(&recordWriter{rec}, q)
+		w.Header().Set("Content-Type", dohMediaType)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer doh.Close()
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		r, err := NewResolver(doh.URL + "/dns-query")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Upstreams[0].Method = method
+		// the test server certificate is self-signed
+		r.HTTP = &gcurl.ConnectionOption{InsecureSkipVerify: true, RequestTimeout: 5 * time.Second, DialTimeout: time.Second}
+		var dials atomic.Int32
+		r.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dials.Add(1)
+			// the connect timeout of the HTTP option applies
+			if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Second {
+				t.Errorf("%s: dial deadline %v, %v", method, deadline, ok)
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		}
+		addrs, err := r.LookupHost(context.Background(), "example.test")
+		if err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.4" {
+			t.Errorf("%s: addrs %v, err %v", method, addrs, err)
+		}
+		if dials.Load() == 0 {
+			t.Errorf("%s: the Dial hook was not used", method)
+		}
+		if transport := r.httpClient.Transport.(*http.Transport); transport.Proxy == nil {
+			t.Errorf("%s: the proxy of the environment is not used", method)
+		}
+		r.CloseIdleConnections()
+	}
+	if len(methods) != 4 || methods[0] != http.MethodGet || methods[3] != http.MethodPost {
+		t.Errorf("methods %v", methods)
+	}
+}
+
+// recordWriter is a dns.ResponseWriter writing the packed answer to a recorder.
+type recordWriter struct {
+	rec *httptest.ResponseRecorder
+}
+
+func (w *recordWriter) LocalAddr() net.Addr         { return &net.TCPAddr{} }
+func (w *recordWriter) RemoteAddr() net.Addr        { return &net.TCPAddr{} }
+func (w *recordWriter) Write(b []byte) (int, error) { return w.rec.Write(b) }
+func (w *recordWriter) Close() error                { return nil }
+func (w *recordWriter) TsigStatus() error           { return nil }
+func (w *recordWriter) TsigTimersOnly(bool)         {}
+func (w *recordWriter) Hijack()                     {}
+func (w *recordWriter) WriteMsg(m *dns.Msg) error {
+	wire, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.rec.Write(wire)
+	return err
+}
+
+// countListener counts the accepted connections.
+type countListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+func TestResolverDoT(t *testing.T) {
+	// borrow the certificate of the test http server, valid for example.com
+	web := httptest.NewUnstartedServer(nil)
+	web.StartTLS()
+	cert, leaf := web.TLS.Certificates[0], web.Certificate()
+	web.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	var sni atomic.Value
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			sni.Store(hello.ServerName)
+			return nil, nil
+		},
+	}
+	l, err := tls.Listen("tcp4", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counted := &countListener{Listener: l}
+	server := &dns.Server{Listener: counted, Net: "tcp-tls", Handler: answerA(5, 0)}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	r, err := NewResolver("tls://" + l.Addr().String() + "#example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Upstreams[0].RootCAs = roots
+	defer r.CloseIdleConnections()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addrs, err := r.LookupHost(context.Background(), "example.test")
+			if err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.5" {
+				t.Errorf("addrs %v, err %v", addrs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err = r.LookupHost(context.Background(), "example.test"); err != nil {
+		t.Error(err)
+	}
+	if name, _ := sni.Load().(string); name != "example.com" {
+		t.Errorf("sni %q", name)
+	}
+	// the queries share the connection dialed by the first one
+	if n := counted.accepted.Load(); n != 1 {
+		t.Errorf("%d connections for 18 queries", n)
+	}
+
+	pinned, err := NewResolver("tls://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned.Upstreams[0].SPKIPins = []string{SPKIPin(leaf)}
+	defer pinned.CloseIdleConnections()
+	if _, err = pinned.LookupHost(context.Background(), "example.test"); err != nil {
+		t.Errorf("pinned: %v", err)
+	}
+	// the connection verified with the right pin is not reused under another one
+	pinned.Upstreams[0].SPKIPins = []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}
+	if _, err = pinned.LookupHost(context.Background(), "example.test"); err == nil {
+		t.Error("wrong pin accepted")
+	}
+}
+
+func TestDotConnReleaseReusedID(t *testing.T) {
+	c := &dotConn{pending: make(map[uint16]chan *dns.Msg), idle: time.NewTimer(time.Hour)}
+	defer c.idle.Stop()
+	// read forgot the answered query 1, and a new query took its id
+	answered, next := make(chan *dns.Msg, 1), make(chan *dns.Msg, 1)
+	c.pending[1] = next
+	c.release(1, answered)
+	if c.pending[1] != next {
+		t.Error("release forgot the query reusing the id")
+	}
+	c.release(1, next)
+	if len(c.pending) != 0 {
+		t.Errorf("%d queries pending", len(c.pending))
+	}
+}
@@ -0,0 +1,111 @@
+package gonetlibs
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Route is one entry of the kernel routing table.
+type Route struct {
+	Family   int        // 4 or 6
+	Dst      *net.IPNet // nil for the default route
+	Gateway  net.IP     // nil for directly connected routes
+	Src      net.IP     // preferred source address, may be nil
+	Iface    string
+	IfIndex  int
+	Metric   int
+	Table    int
+	Protocol string // who installed the route: kernel, boot, static, dhcp, ra...
+}
+
+// IsDefault reports whether the route is a default route (0.0.0.0/0 or ::/0).
+func (r Route) IsDefault() bool {
+	if r.Dst == nil {
+		return true
+	}
+	ones, _ := r.Dst.Mask.Size()
+	return ones == 0
+}
+
+func (r Route) String() string {
+	dst := "default"
+	if !r.IsDefault() {
+		dst = r.Dst.String()
+	}
+	s := dst
+	if r.Gateway != nil {
+		s += " via " + r.Gateway.String()
+	}
+	if len(r.Iface) != 0 {
+		s += " dev " + r.Iface
+	}
+	if r.Src != nil {
+		s += " src " + r.Src.String()
+	}
+	if r.Metric != 0 {
+		s += " metric " + strconv.Itoa(r.Metric)
+	}
+	return s
+}
+
+// NetDefaultGateways returns the default routes of the main table, lowest metric first.
+func NetDefaultGateways(family IPFamily) ([]Route, error) {
+	routes, err := NetRoutes(family)
+	if err != nil {
+		return nil, err
+	}
+	gateways := make([]Route, 0)
+	for _, r := range routes {
+		if r.IsDefault() && r.Table == routeTableMain {
+			gateways = append(gateways, r)
+		}
+	}
+	sort.SliceStable(gateways, func(i, j int) bool {
+		return gateways[i].Metric < gateways[j].Metric
+	})
+	return gateways, nil
+}
+
+// NetDefaultGateway returns the default route of interface ifacename with the lowest metric.
+func NetDefaultGateway(ifacename string, family IPFamily) (*Route, error) {
+	gateways, err := NetDefaultGateways(family)
+	if err != nil {
+		return nil, err
+	}
+	for i := range gateways {
+		if gateways[i].Iface == ifacename {
+			return &gateways[i], nil
+		}
+	}
+	return nil, fmt.Errorf("There isn't any default gateway on interface %s", ifacename)
+}
+
+/*
+NetRouteIface returns the interface and source address the kernel would use to
+reach host (domain or IP).
+*/
+func NetRouteIface(host string) (iface string, src net.IP, err error) {
+	r, err := NetRouteGet(host)
+	if err != nil {
+		return "", nil, err
+	}
+	return r.Iface, r.Src, nil
+}
+
+// resolveRouteTarget turns a domain or a (zoned) IP into the address to look up.
+func resolveRouteTarget(host string) (*net.IPAddr, error) {
+	if ip, _, _ := strings.Cut(host, "%"); net.ParseIP(ip) == nil {
+		addrs, err := ResolverDomain(host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("can not resolve %s", host)
+		}
+		host = addrs[0]
+	}
+	return net.ResolveIPAddr("ip", host)
+}
@@ -0,0 +1,169 @@
+package gonetlibs
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const routeTableMain = unix.RT_TABLE_MAIN
+
+var routeProtocols = map[uint8]string{
+	unix.RTPROT_UNSPEC:   "unspec",
+	unix.RTPROT_REDIRECT: "redirect",
+	unix.RTPROT_KERNEL:   "kernel",
+	unix.RTPROT_BOOT:     "boot",
+	unix.RTPROT_STATIC:   "static",
+	unix.RTPROT_RA:       "ra",
+	unix.RTPROT_DHCP:     "dhcp",
+}
+
+// NetRoutes dumps the unicast routes of every routing table for a family.
+func NetRoutes(family IPFamily) ([]Route, error) {
+	var families []uint8
+	switch family {
+	case IPFamilyV4:
+		families = []uint8{unix.AF_INET}
+	case IPFamilyV6:
+		families = []uint8{unix.AF_INET6}
+	default:
+		families = []uint8{unix.AF_INET, unix.AF_INET6}
+	}
+	names, err := ifaceNames()
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]Route, 0)
+	for _, af := range families {
+		msgs, err := nlDump(unix.RTM_GETROUTE, af, unix.SizeofRtMsg)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if len(m.Data) < unix.SizeofRtMsg || m.Data[7] != unix.RTN_UNICAST {
+				continue
+			}
+			routes = append(routes, parseRouteMessage(m, names)...)
+		}
+	}
+	return routes, nil
+}
+
+/*
+NetRouteGet asks the kernel which route it would use to reach host (domain or
+IP, link-local addresses may carry a zone), like `ip route get`.
+*/
+func NetRouteGet(host string) (*Route, error) {
+	dst, err := resolveRouteTarget(host)
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, unix.SizeofRtMsg)
+	ip := dst.IP.To4()
+	body[0] = unix.AF_INET
+	if ip == nil {
+		ip = dst.IP.To16()
+		body[0] = unix.AF_INET6
+	}
+	body[1] = uint8(8 * len(ip)) // rtm_dst_len
+	body = append(body, nlEncodeAttr(unix.RTA_DST, ip)...)
+	if len(dst.Zone) != 0 {
+		ief, err := net.InterfaceByName(dst.Zone)
+		if err != nil {
+			return nil, err
+		}
+		oif := make([]byte, 4)
+		binary.NativeEndian.PutUint32(oif, uint32(ief.Index))
+		body = append(body, nlEncodeAttr(unix.RTA_OIF, oif)...)
+	}
+
+	msgs, err := nlRequest(unix.RTM_GETROUTE, 0, body)
+	if err != nil {
+		return nil, err
+	}
+	names, err := ifaceNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWROUTE {
+			continue
+		}
+		if routes := parseRouteMessage(m, names); len(routes) != 0 {
+			return &routes[0], nil
+		}
+	}
+	return nil, &net.OpError{Op: "route", Net: "ip", Addr: dst, Err: syscall.ENETUNREACH}
+}
+
+// parseRouteMessage decodes a RTM_NEWROUTE message, multipath routes give one Route per next hop.
+func parseRouteMessage(m syscall.NetlinkMessage, names map[int]string) []Route {
+	r := Route{Family: 4, Table: int(m.Data[4]), Protocol: routeProtocols[m.Data[5]]}
+	bits := 8 * net.IPv4len
+	if m.Data[0] == unix.AF_INET6 {
+		r.Family, bits = 6, 8*net.IPv6len
+	}
+	dstLen := int(m.Data[1])
+
+	var multipath []byte
+	for _, a := range nlParseAttrs(m.Data[unix.SizeofRtMsg:]) {
+		switch a.Type {
+		case unix.RTA_DST:
+			r.Dst = &net.IPNet{IP: append(net.IP(nil), a.Value...), Mask: net.CIDRMask(dstLen, bits)}
+		case unix.RTA_GATEWAY:
+			r.Gateway = append(net.IP(nil), a.Value...)
+		case unix.RTA_PREFSRC:
+			r.Src = append(net.IP(nil), a.Value...)
+		case unix.RTA_OIF:
+			r.IfIndex = int(nlUint32(a.Value))
+		case unix.RTA_PRIORITY:
+			r.Metric = int(nlUint32(a.Value))
+		case unix.RTA_TABLE:
+			r.Table = int(nlUint32(a.Value))
+		case unix.RTA_MULTIPATH:
+			multipath = a.Value
+		}
+	}
+	if r.Dst == nil && dstLen != 0 {
+		return nil
+	}
+
+	if multipath == nil {
+		r.Iface = names[r.IfIndex]
+		return []Route{r}
+	}
+	// struct rtnexthop { len u16; flags u8; hops u8; ifindex i32 } followed by attributes
+	routes := make([]Route, 0)
+	for len(multipath) >= unix.SizeofRtNexthop {
+		l := int(binary.NativeEndian.Uint16(multipath[0:2]))
+		if l < unix.SizeofRtNexthop || l > len(multipath) {
+			break
+		}
+		hop := r
+		hop.IfIndex = int(binary.NativeEndian.Uint32(multipath[4:8]))
+		hop.Iface = names[hop.IfIndex]
+		for _, a := range nlParseAttrs(multipath[unix.SizeofRtNexthop:l]) {
+			if a.Type == unix.RTA_GATEWAY {
+				hop.Gateway = append(net.IP(nil), a.Value...)
+			}
+		}
+		routes = append(routes, hop)
+		// the last next hop may not be padded
+		multipath = multipath[min(nlAlign(l), len(multipath)):]
+	}
+	return routes
+}
+
+func ifaceNames() (map[int]string, error) {
+	ifaces, err := listIfaces()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(ifaces))
+	for _, info := range ifaces {
+		names[info.Index] = info.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,31 @@
+package gonetlibs
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseRouteMultipath(t *testing.T) {
+	nexthop := func(ifindex int, attrs []byte) []byte {
+		b := make([]byte, unix.SizeofRtNexthop, unix.SizeofRtNexthop+len(attrs))
+		binary.NativeEndian.PutUint16(b[0:2], uint16(unix.SizeofRtNexthop+len(attrs)))
+		binary.NativeEndian.PutUint32(b[4:8], uint32(ifindex))
+		return append(b, attrs...)
+	}
+	// the last next hop ends with a 1 byte attribute, without padding
+	unpadded := []byte{5, 0, unix.RTA_FLOW, 0, 1}
+	multipath := append(nexthop(2, nlEncodeAttr(unix.RTA_GATEWAY, []byte{10, 0, 0, 1})),
+		nexthop(3, append(nlEncodeAttr(unix.RTA_GATEWAY, []byte{10, 0, 1, 1}), unpadded...))...)
+	data := make([]byte, unix.SizeofRtMsg)
+	data[0], data[4] = unix.AF_INET, unix.RT_TABLE_MAIN
+	data = append(data, nlEncodeAttr(unix.RTA_MULTIPATH, multipath)...)
+
+	routes := parseRouteMessage(syscall.NetlinkMessage{Data: data}, map[int]string{2: "eth0", 3: "eth1"})
+	if len(routes) != 2 || routes[0].Iface != "eth0" || routes[1].Iface != "eth1" ||
+		routes[0].Gateway.String() != "10.0.0.1" || routes[1].Gateway.String() != "10.0.1.1" {
+		t.Errorf("routes %v", routes)
+	}
+}
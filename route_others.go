@@ -0,0 +1,20 @@
+//go:build !linux
+
+package gonetlibs
+
+import (
+	"fmt"
+	"runtime"
+)
+
+const routeTableMain = 254
+
+// NetRoutes needs rtnetlink and is only available on Linux.
+func NetRoutes(family IPFamily) ([]Route, error) {
+	return nil, fmt.Errorf("NetRoutes is not supported on %s", runtime.GOOS)
+}
+
+// NetRouteGet needs rtnetlink and is only available on Linux.
+func NetRouteGet(host string) (*Route, error) {
+	return nil, fmt.Errorf("NetRouteGet is not supported on %s", runtime.GOOS)
+}
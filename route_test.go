@@ -0,0 +1,38 @@
+package gonetlibs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRouteString(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("192.168.1.0/24")
+	tests := []struct {
+		route     Route
+		isDefault bool
+		want      string
+	}{
+		{Route{Gateway: net.ParseIP("192.168.1.1"), Iface: "eth0", Metric: 100}, true, "default via 192.168.1.1 dev eth0 metric 100"},
+		{Route{Dst: &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}, Gateway: net.ParseIP("fd00::1"), Iface: "eth0"}, true, "default via fd00::1 dev eth0"},
+		{Route{Dst: dst, Iface: "eth0", Src: net.ParseIP("192.168.1.10")}, false, "192.168.1.0/24 dev eth0 src 192.168.1.10"},
+	}
+	for _, tt := range tests {
+		if got := tt.route.IsDefault(); got != tt.isDefault {
+			t.Errorf("%s: IsDefault() = %v, want %v", tt.want, got, tt.isDefault)
+		}
+		if got := tt.route.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestNetRouteGet(t *testing.T) {
+	r, err := NetRouteGet("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Src.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("route to 127.0.0.1 uses source %v", r.Src)
+	}
+	t.Logf("%s", r)
+}
@@ -0,0 +1,294 @@
+package gonetlibs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PortState is the result of probing one port.
+type PortState int
+
+const (
+	PortOpen     PortState = iota + 1
+	PortClosed             // the host answered with a reset or an ICMP port unreachable
+	PortFiltered           // no answer before the timeout, for udp this also covers open ports that stay silent
+)
+
+func (s PortState) String() string {
+	switch s {
+	case PortOpen:
+		return "open"
+	case PortClosed:
+		return "closed"
+	case PortFiltered:
+		return "filtered"
+	}
+	return "PortState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// ScanOptions tunes NetScan. A nil *ScanOptions uses the defaults.
+type ScanOptions struct {
+	Concurrency   int           // probes in flight, default 100
+	Timeout       time.Duration // connect timeout, default 666ms like ServerIsLive
+	UDP           bool          // probe udp ports instead of tcp
+	Banner        bool          // read the first bytes sent by open tcp ports, or their TLS certificate subject
+	BannerTimeout time.Duration // how long to wait for a banner, default 1s
+	BannerSize    int           // bytes kept from a banner, default 256
+	Iface         string        // source interface, like ifacenames in ServerIsLive
+}
+
+// PortResult is the state of one host:port.
+type PortResult struct {
+	Host       string
+	IP         net.IP
+	Port       int
+	Proto      string // tcp or udp
+	State      PortState
+	RTT        time.Duration
+	Banner     string
+	TLSSubject string
+	Err        error // set when the host could not be resolved
+}
+
+/*
+ParsePorts parses a port list such as "22,80,443,8000-8100".
+*/
+func ParsePorts(spec string) ([]int, error) {
+	ports := make([]int, 0)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("invalid port range %q", part)
+			}
+		}
+		if first < 1 || last > 65535 || first > last {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		for p := first; p <= last; p++ {
+			ports = append(ports, p)
+		}
+	}
+	return ports, nil
+}
+
+/*
+NetScan probes every port of every host with bounded concurrency and streams one
+PortResult per host:port. The channel is closed when the scan ends or ctx is done.
+*/
+func NetScan(ctx context.Context, hosts []string, ports []int, opts *ScanOptions) (<-chan PortResult, error) {
+	o := ScanOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 100
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Millisecond * 666
+	}
+	if o.BannerTimeout <= 0 {
+		o.BannerTimeout = time.Second
+	}
+	if o.BannerSize <= 0 {
+		o.BannerSize = 256
+	}
+	for _, p := range ports {
+		if p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid port %d", p)
+		}
+	}
+
+	results := make(chan PortResult, o.Concurrency)
+	go func() {
+		defer close(results)
+		var (
+			wg  sync.WaitGroup
+			sem = make(chan struct{}, o.Concurrency)
+		)
+		send := func(r PortResult) {
+			select {
+			case results <- r:
+			case <-ctx.Done():
+			}
+		}
+	loop:
+		for _, host := range hosts {
+			ip, err := resolveHostIP(host)
+			if err != nil {
+				send(PortResult{Host: host, Err: err})
+				continue
+			}
+			local, err := scanLocalAddr(o.Iface, ip)
+			if err != nil {
+				send(PortResult{Host: host, IP: ip, Err: err})
+				continue
+			}
+			for _, port := range ports {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					break loop
+				}
+				wg.Add(1)
+				go func(host string, port int) {
+					defer wg.Done()
+					defer func() { <-sem }()
+					r := PortResult{Host: host, IP: ip, Port: port}
+					if o.UDP {
+						scanUDP(ctx, &r, local, &o)
+					} else {
+						scanTCP(ctx, &r, local, &o)
+					}
+					send(r)
+				}(host, port)
+			}
+		}
+		wg.Wait()
+	}()
+	return results, nil
+}
+
+// NetScanAll runs NetScan and waits for its end.
+func NetScanAll(hosts []string, ports []int, opts *ScanOptions) ([]PortResult, error) {
+	results, err := NetScan(context.Background(), hosts, ports, opts)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]PortResult, 0)
+	for r := range results {
+		all = append(all, r)
+	}
+	return all, nil
+}
+
+func scanTCP(ctx context.Context, r *PortResult, local net.IP, o *ScanOptions) {
+	r.Proto = "tcp"
+	d := net.Dialer{Timeout: o.Timeout}
+	if local != nil {
+		d.LocalAddr = &net.TCPAddr{IP: local}
+	}
+	addr := net.JoinHostPort(r.IP.String(), strconv.Itoa(r.Port))
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	r.RTT = time.Since(start)
+	if err != nil {
+		r.State = scanErrState(err)
+		return
+	}
+	r.State = PortOpen
+	if !o.Banner {
+		conn.Close()
+		return
+	}
+
+	buf := make([]byte, o.BannerSize)
+	conn.SetReadDeadline(time.Now().Add(o.BannerTimeout))
+	n, _ := conn.Read(buf)
+	conn.Close()
+	if n > 0 {
+		r.Banner = string(buf[:n])
+		return
+	}
+	// silent service, it may be waiting for a TLS client hello
+	conn, err = d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tlsconn := tls.Client(conn, &tls.Config{ServerName: r.Host, InsecureSkipVerify: true})
+	tlsconn.SetDeadline(time.Now().Add(o.BannerTimeout))
+	if err := tlsconn.HandshakeContext(ctx); err == nil {
+		if certs := tlsconn.ConnectionState().PeerCertificates; len(certs) != 0 {
+			r.TLSSubject = certs[0].Subject.String()
+		}
+	}
+}
+
+func scanUDP(ctx context.Context, r *PortResult, local net.IP, o *ScanOptions) {
+	r.Proto = "udp"
+	d := net.Dialer{Timeout: o.Timeout}
+	if local != nil {
+		d.LocalAddr = &net.UDPAddr{IP: local}
+	}
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(r.IP.String(), strconv.Itoa(r.Port)))
+	if err != nil {
+		r.State = scanErrState(err)
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(o.Timeout))
+	if _, err = conn.Write([]byte{0}); err == nil {
+		buf := make([]byte, o.BannerSize)
+		var n int
+		if n, err = conn.Read(buf); err == nil {
+			r.Banner = string(buf[:n])
+		}
+	}
+	r.RTT = time.Since(start)
+	if err == nil {
+		r.State = PortOpen
+		return
+	}
+	r.State = scanErrState(err)
+}
+
+// scanErrState maps a dial or read error to a port state.
+func scanErrState(err error) PortState {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return PortClosed
+	}
+	return PortFiltered
+}
+
+// resolveHostIP resolves a host like ServerIsLive does, falling back to IPv6.
+func resolveHostIP(host string) (net.IP, error) {
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return ip, nil
+	}
+	ip, err := ResolverDomain2Ip4(host)
+	if err != nil {
+		if ip, err = ResolverDomain2Ip6(host); err != nil {
+			return nil, err
+		}
+	}
+	return net.ParseIP(ip), nil
+}
+
+// scanLocalAddr returns the address of ifacename in the family of dst, nil without interface.
+func scanLocalAddr(ifacename string, dst net.IP) (net.IP, error) {
+	if len(ifacename) == 0 {
+		return nil, nil
+	}
+	var (
+		local string
+		err   error
+	)
+	if dst.To4() != nil {
+		local, err = NetGetInterfaceIpv4Addr(ifacename)
+	} else {
+		local, err = NetGetInterfaceIpv6Addr(ifacename, Ip6ScopeGlobal, Ip6ScopeULA)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return net.ParseIP(local), nil
+}
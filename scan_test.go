@@ -0,0 +1,101 @@
+package gonetlibs
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParsePorts(t *testing.T) {
+	ports, err := ParsePorts("22, 80,8000-8002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{22, 80, 8000, 8001, 8002}; !reflect.DeepEqual(ports, want) {
+		t.Errorf("ParsePorts = %v, want %v", ports, want)
+	}
+	for _, bad := range []string{"0", "70000", "90-80", "http"} {
+		if _, err := ParsePorts(bad); err == nil {
+			t.Errorf("ParsePorts(%q) accepted", bad)
+		}
+	}
+}
+
+func TestNetScanAll(t *testing.T) {
+	banner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer banner.Close()
+	go func() {
+		for {
+			conn, err := banner.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-test\r\n"))
+			conn.Close()
+		}
+	}()
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	// a port that was just released is closed
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	bannerPort := banner.Addr().(*net.TCPAddr).Port
+	_, tlsPortStr, _ := net.SplitHostPort(tlsServer.Listener.Addr().String())
+	tlsPort, _ := strconv.Atoi(tlsPortStr)
+
+	results, err := NetScanAll([]string{"127.0.0.1"}, []int{bannerPort, tlsPort, closedPort},
+		&ScanOptions{Banner: true, BannerTimeout: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[int]PortResult)
+	for _, r := range results {
+		got[r.Port] = r
+	}
+	if r := got[bannerPort]; r.State != PortOpen || r.Banner != "SSH-2.0-test\r\n" {
+		t.Errorf("banner port: %+v", r)
+	}
+	if r := got[tlsPort]; r.State != PortOpen || len(r.TLSSubject) == 0 {
+		t.Errorf("tls port: %+v", r)
+	}
+	if r := got[closedPort]; r.State != PortClosed {
+		t.Errorf("closed port: %+v", r)
+	}
+}
+
+func TestNetScanUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			_, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo([]byte("pong"), addr)
+		}
+	}()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+
+	results, err := NetScanAll([]string{"127.0.0.1"}, []int{port}, &ScanOptions{UDP: true, Timeout: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].State != PortOpen || results[0].Banner != "pong" {
+		t.Errorf("udp scan: %+v", results)
+	}
+}
@@ -0,0 +1,181 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SweepOptions tunes NetSweep. A nil *SweepOptions uses the defaults.
+type SweepOptions struct {
+	Concurrency int           // probes in flight, default 64
+	Rate        int           // probes started per second, 0 for no limit
+	Timeout     time.Duration // per probe, default 1s
+	TCPPorts    []int         // ports tried when ICMP gets no answer, default 80, 443, 22. Empty slice disables the fallback.
+}
+
+// SweepHost is a host that answered during NetSweep.
+type SweepHost struct {
+	IP     net.IP
+	RTT    time.Duration
+	Method string // "icmp" or "tcp/<port>"
+}
+
+var defaultSweepPorts = []int{80, 443, 22}
+
+/*
+NetSweep probes every host of target, a CIDR (192.168.1.0/24) or an interface
+name whose IPv4 subnet is used, and streams the hosts that answer. Each host is
+pinged, then TCP ports are tried since a refused connection also proves the
+host is up. The channel is closed once every host was probed or ctx is done.
+*/
+func NetSweep(ctx context.Context, target string, opts *SweepOptions) (<-chan SweepHost, error) {
+	o := SweepOptions{Concurrency: 64, Timeout: time.Second, TCPPorts: defaultSweepPorts}
+	if opts != nil {
+		o = *opts
+		if o.Concurrency <= 0 {
+			o.Concurrency = 64
+		}
+		if o.Timeout <= 0 {
+			o.Timeout = time.Second
+		}
+		if o.TCPPorts == nil {
+			o.TCPPorts = defaultSweepPorts
+		}
+	}
+	hosts, iface, err := sweepTargets(target)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(chan SweepHost, o.Concurrency)
+	// every host is pinged through the one socket of the engine
+	engine := NewICMPEngine(&ICMPEngineOptions{Iface: iface, Timeout: o.Timeout})
+	go func() {
+		defer close(results)
+		defer engine.Close()
+		var (
+			wg   sync.WaitGroup
+			sem  = make(chan struct{}, o.Concurrency)
+			tick <-chan time.Time
+		)
+		if o.Rate > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(o.Rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for _, ip := range hosts {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+				}
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Add(1)
+			go func(ip net.IP) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if h, ok := sweepProbe(ctx, engine, ip, iface, &o); ok {
+					select {
+					case results <- h:
+					case <-ctx.Done():
+					}
+				}
+			}(ip)
+		}
+		wg.Wait()
+	}()
+	return results, nil
+}
+
+// NetSweepAll runs NetSweep and waits for its end.
+func NetSweepAll(target string, opts *SweepOptions) ([]SweepHost, error) {
+	results, err := NetSweep(context.Background(), target, opts)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]SweepHost, 0)
+	for h := range results {
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// sweepTargets expands a CIDR or an interface name into the hosts to probe.
+func sweepTargets(target string) (hosts []net.IP, iface string, err error) {
+	if _, ipnet, err := net.ParseCIDR(target); err == nil {
+		// a cidr names a network, its address is swept like the others
+		ones, _ := ipnet.Mask.Size()
+		hosts, err = networkHosts(IfaceAddr{IP: ipnet.IP.Mask(ipnet.Mask), PrefixLen: ones}, false)
+		return hosts, "", err
+	}
+	d, err := NetInterfaceDetails(target)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, a := range d.Addrs {
+		if a.Family == 4 {
+			hosts, err = subnetHosts(a)
+			return hosts, target, err
+		}
+	}
+	return nil, "", &IfaceError{Iface: target, Err: ErrNoIPv4, NoAddr: len(d.Addrs) == 0}
+}
+
+func sweepProbe(ctx context.Context, engine *ICMPEngine, ip net.IP, iface string, o *SweepOptions) (SweepHost, bool) {
+	echoes := make(chan EchoResult, 1)
+	if err := engine.Send(&net.IPAddr{IP: ip}, o.Timeout, func(e EchoResult) { echoes <- e }); err == nil {
+		select {
+		case e := <-echoes:
+			if e.Err == nil {
+				return SweepHost{IP: ip, RTT: e.RTT, Method: "icmp"}, true
+			}
+		case <-ctx.Done():
+			return SweepHost{}, false
+		}
+	}
+	if len(o.TCPPorts) == 0 || ctx.Err() != nil {
+		return SweepHost{}, false
+	}
+
+	d := net.Dialer{Timeout: o.Timeout}
+	if len(iface) != 0 {
+		if ip4, err := NetGetInterfaceIpv4Addr(iface); err == nil {
+			d.LocalAddr = &net.TCPAddr{IP: net.ParseIP(ip4)}
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := make(chan SweepHost, len(o.TCPPorts))
+	for _, port := range o.TCPPorts {
+		go func(port int) {
+			start := time.Now()
+			conn, err := d.DialContext(ctx, "tcp4", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+			if err == nil {
+				conn.Close()
+			}
+			if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+				found <- SweepHost{IP: ip, RTT: time.Since(start), Method: "tcp/" + strconv.Itoa(port)}
+			} else {
+				found <- SweepHost{}
+			}
+		}(port)
+	}
+	for range o.TCPPorts {
+		if h := <-found; h.IP != nil {
+			return h, true
+		}
+	}
+	return SweepHost{}, false
+}
@@ -0,0 +1,41 @@
+package gonetlibs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSweepTargets(t *testing.T) {
+	hosts, iface, err := sweepTargets("10.1.2.0/30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(iface) != 0 || len(hosts) != 2 || hosts[0].String() != "10.1.2.1" || hosts[1].String() != "10.1.2.2" {
+		t.Errorf("sweepTargets = %v %q", hosts, iface)
+	}
+	// the host bits of a cidr are ignored, the address given is swept too
+	hosts, _, err = sweepTargets("10.1.2.2/30")
+	if err != nil || len(hosts) != 2 || hosts[1].String() != "10.1.2.2" {
+		t.Errorf("sweepTargets = %v, %v", hosts, err)
+	}
+	if hosts, _, err = sweepTargets("10.1.2.2/32"); err != nil || len(hosts) != 1 || hosts[0].String() != "10.1.2.2" {
+		t.Errorf("sweepTargets = %v, %v", hosts, err)
+	}
+	if _, _, err := sweepTargets("no-such-iface0"); err == nil {
+		t.Error("unknown interface accepted")
+	}
+}
+
+func TestNetSweepLoopback(t *testing.T) {
+	hosts, err := NetSweepAll("127.0.0.0/30", &SweepOptions{Timeout: 300 * time.Millisecond, Rate: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// loopback addresses answer ping when allowed, or refuse the tcp connection
+	if len(hosts) != 2 {
+		t.Errorf("found %v, want 127.0.0.1 and 127.0.0.2", hosts)
+	}
+	for _, h := range hosts {
+		t.Logf("%s %s %s", h.IP, h.Method, h.RTT)
+	}
+}
@@ -0,0 +1,567 @@
+package gonetlibs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// TraceProto selects the probes sent by Traceroute.
+type TraceProto int
+
+const (
+	TraceUDP  TraceProto = iota // datagrams to increasing ports from 33434, like traceroute
+	TraceICMP                   // echo requests, like traceroute -I
+	TraceTCP                    // SYN to Port, like traceroute -T. Linux only.
+)
+
+func (p TraceProto) String() string {
+	switch p {
+	case TraceUDP:
+		return "udp"
+	case TraceICMP:
+		return "icmp"
+	case TraceTCP:
+		return "tcp"
+	}
+	return fmt.Sprintf("TraceProto(%d)", int(p))
+}
+
+// TraceOptions tunes Traceroute. A nil *TraceOptions uses the defaults.
+type TraceOptions struct {
+	Proto        TraceProto
+	Iface        string        // interface whose address is used as source, like Ping
+	Family       IPFamily      // any follows the address, domains try IPv4 then IPv6
+	Port         int           // destination port, default 33434 for udp (one more per probe) and 80 for tcp
+	FirstTTL     int           // default 1
+	MaxTTL       int           // default 30
+	Queries      int           // probes per hop, default 3
+	Timeout      time.Duration // wait for an answer to each probe, default 1s
+	ResolveNames bool          // look up the reverse DNS name of every hop
+	OnHop        func(TraceHop)
+}
+
+// TraceProbe is the answer to one probe of a hop.
+type TraceProbe struct {
+	Addr net.IP // who answered, nil when the probe timed out
+	Name string // reverse DNS name of Addr when TraceOptions.ResolveNames is set
+	RTT  time.Duration
+	Err  error // destination unreachable reported by Addr, nil otherwise
+}
+
+// TraceHop is the answers to the probes sent with one TTL.
+type TraceHop struct {
+	TTL    int
+	Probes []TraceProbe
+}
+
+// Addrs lists the distinct addresses that answered for this hop.
+func (h TraceHop) Addrs() []net.IP {
+	addrs := make([]net.IP, 0, 1)
+next:
+	for _, p := range h.Probes {
+		if p.Addr == nil {
+			continue
+		}
+		for _, a := range addrs {
+			if a.Equal(p.Addr) {
+				continue next
+			}
+		}
+		addrs = append(addrs, p.Addr)
+	}
+	return addrs
+}
+
+func (h TraceHop) String() string {
+	var (
+		b    strings.Builder
+		last net.IP
+	)
+	fmt.Fprintf(&b, "%2d ", h.TTL)
+	for _, p := range h.Probes {
+		if p.Addr == nil {
+			b.WriteString(" *")
+			continue
+		}
+		if !p.Addr.Equal(last) {
+			if len(p.Name) != 0 {
+				fmt.Fprintf(&b, " %s (%s)", p.Name, p.Addr)
+			} else {
+				fmt.Fprintf(&b, " %s", p.Addr)
+			}
+			last = p.Addr
+		}
+		fmt.Fprintf(&b, "  %.3f ms", float64(p.RTT)/float64(time.Millisecond))
+		if p.Err != nil {
+			fmt.Fprintf(&b, " %s", unreachableFlag(p.Err))
+		}
+	}
+	return b.String()
+}
+
+// TraceResult is the path found by Traceroute.
+type TraceResult struct {
+	Dst     *net.IPAddr
+	Proto   TraceProto
+	Hops    []TraceHop
+	Reached bool // the destination itself answered
+}
+
+func (r *TraceResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "traceroute to %s (%s), %d hops", r.Dst, r.Proto, len(r.Hops))
+	for _, h := range r.Hops {
+		b.WriteString("\n")
+		b.WriteString(h.String())
+	}
+	return b.String()
+}
+
+// LastHop returns the farthest hop that answered, where the path breaks when the destination was not reached. nil if no hop answered.
+func (r *TraceResult) LastHop() *TraceHop {
+	for i := len(r.Hops) - 1; i >= 0; i-- {
+		if len(r.Hops[i].Addrs()) != 0 {
+			return &r.Hops[i]
+		}
+	}
+	return nil
+}
+
+// UnreachableError is a destination unreachable received by Traceroute.
+type UnreachableError struct {
+	From net.IP
+	V6   bool
+	Code int
+}
+
+func (e *UnreachableError) Error() string {
+	reason := "destination unreachable, code " + strconv.Itoa(e.Code)
+	codes := map[int]string{0: "network unreachable", 1: "host unreachable", 2: "protocol unreachable", 3: "port unreachable",
+		4: "fragmentation needed", 9: "network prohibited", 10: "host prohibited", 13: "communication prohibited"}
+	if e.V6 {
+		codes = map[int]string{0: "no route to destination", 1: "communication prohibited", 3: "address unreachable", 4: "port unreachable"}
+	}
+	if s, ok := codes[e.Code]; ok {
+		reason = s
+	}
+	return fmt.Sprintf("%s from %s", reason, e.From)
+}
+
+// unreachableFlag is the traceroute annotation of an unreachable error: !N, !H, !P, !X...
+func unreachableFlag(err error) string {
+	var e *UnreachableError
+	if !errors.As(err, &e) {
+		return "!?"
+	}
+	flags := map[int]string{0: "!N", 1: "!H", 2: "!P", 4: "!F", 9: "!X", 10: "!X", 13: "!X"}
+	if e.V6 {
+		flags = map[int]string{0: "!N", 1: "!X", 3: "!H"}
+	}
+	if f, ok := flags[e.Code]; ok {
+		return f
+	}
+	return "!<" + strconv.Itoa(e.Code) + ">"
+}
+
+// traceMsg is an ICMP message read during a traceroute.
+type traceMsg struct {
+	peer net.IP
+	at   time.Time
+	echo *icmp.Echo // echo reply
+	err  *icmpError // error quoting one of our probes
+}
+
+// traceProber sends one probe with a TTL and tells whether a message answers it.
+type traceProber interface {
+	// send sends the probe, done receives the answer when the probe itself learns it (tcp connect)
+	send(ttl, seq int) (done <-chan TraceProbe, err error)
+	match(m *traceMsg, seq int) bool
+	Close() error
+}
+
+/*
+Traceroute sends probes with increasing TTL to addr and records who answers:
+routers with time exceeded, the destination with an echo reply, a port
+unreachable (udp) or a SYN-ACK/RST (tcp). It stops when the destination answers,
+a hop reports it unreachable, MaxTTL is passed or ctx is done. ICMP answers are
+read on a raw socket, which needs CAP_NET_RAW.
+*/
+func Traceroute(ctx context.Context, addr string, opts *TraceOptions) (*TraceResult, error) {
+	// stops the reader goroutine blocked on a message nobody waits for anymore
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	o := TraceOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.FirstTTL <= 0 {
+		o.FirstTTL = 1
+	}
+	if o.MaxTTL <= 0 {
+		o.MaxTTL = 30
+	}
+	if o.Queries <= 0 {
+		o.Queries = 3
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Second
+	}
+	if o.Port <= 0 {
+		o.Port = 33434
+		if o.Proto == TraceTCP {
+			o.Port = 80
+		}
+	}
+
+	dst, err := resolvePingTarget(addr, o.Family)
+	if err != nil {
+		return nil, err
+	}
+	v6 := dst.IP.To4() == nil
+	listenAddr, err := ifaceSourceAddr(o.Iface, dst)
+	if err != nil {
+		return nil, err
+	}
+	s, err := listenICMP(ICMPModeRaw, v6, listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	var prober traceProber
+	switch o.Proto {
+	case TraceUDP:
+		prober, err = newTraceUDP(dst, listenAddr, o.Port)
+	case TraceICMP:
+		prober = &traceICMP{s: s, dst: dst, id: int(atomic.AddUint32(&pingerID, 1) & 0xffff)}
+	case TraceTCP:
+		prober = &traceTCP{dst: dst, laddr: listenAddr, port: o.Port, timeout: o.Timeout}
+	default:
+		err = fmt.Errorf("unknown traceroute protocol %s", o.Proto)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer prober.Close()
+
+	msgs := make(chan *traceMsg, 16)
+	go func() {
+		defer close(msgs)
+		proto, replyType := ProtocolICMP, icmp.Type(ipv4.ICMPTypeEchoReply)
+		if v6 {
+			proto, replyType = ProtocolIPv6ICMP, ipv6.ICMPTypeEchoReply
+		}
+		buf := make([]byte, 1500)
+		for {
+			n, _, peer, err := s.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// the message is handed to the probing loop, it can not share buf
+			b := append([]byte(nil), buf[:n]...)
+			m, err := icmp.ParseMessage(proto, b)
+			if err != nil {
+				continue
+			}
+			msg := &traceMsg{peer: peer, at: time.Now()}
+			if echo, ok := m.Body.(*icmp.Echo); ok && m.Type == replyType {
+				msg.echo = echo
+			} else if msg.err = icmpErrorOf(m, b, v6); msg.err == nil {
+				continue
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	names := make(map[string]string)
+	result := &TraceResult{Dst: dst, Proto: o.Proto}
+	seq := 0
+	for ttl := o.FirstTTL; ttl <= o.MaxTTL && ctx.Err() == nil; ttl++ {
+		hop := TraceHop{TTL: ttl}
+		for q := 0; q < o.Queries && ctx.Err() == nil; q++ {
+			seq++
+			p := traceOnce(ctx, prober, msgs, ttl, seq, o.Timeout, v6)
+			if p.Addr != nil && o.ResolveNames {
+				name, ok := names[p.Addr.String()]
+				if !ok {
+					name = reverseName(ctx, p.Addr)
+					names[p.Addr.String()] = name
+				}
+				p.Name = name
+			}
+			hop.Probes = append(hop.Probes, p)
+		}
+		result.Hops = append(result.Hops, hop)
+		if o.OnHop != nil {
+			o.OnHop(hop)
+		}
+		stop := false
+		for _, p := range hop.Probes {
+			if p.Addr != nil && p.Addr.Equal(dst.IP) {
+				result.Reached = true
+			}
+			if p.Addr != nil && (p.Addr.Equal(dst.IP) || p.Err != nil) {
+				stop = true
+			}
+		}
+		if stop {
+			break
+		}
+	}
+	return result, nil
+}
+
+// traceOnce sends one probe and waits for its answer.
+func traceOnce(ctx context.Context, prober traceProber, msgs <-chan *traceMsg, ttl, seq int, timeout time.Duration, v6 bool) TraceProbe {
+	start := time.Now()
+	done, err := prober.send(ttl, seq)
+	if err != nil {
+		return TraceProbe{}
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case p := <-done:
+			return p
+		case m, ok := <-msgs:
+			if !ok {
+				return TraceProbe{}
+			}
+			if !prober.match(m, seq) {
+				continue
+			}
+			p := TraceProbe{Addr: m.peer, RTT: m.at.Sub(start)}
+			if m.err != nil && isUnreachable(m.err.Type) {
+				// the destination saying its port is closed is the end of the udp trace, not an error
+				if m.err.Code != portUnreachableCode(v6) || !m.peer.Equal(m.err.Dst) {
+					p.Err = &UnreachableError{From: m.peer, V6: v6, Code: m.err.Code}
+				}
+			}
+			return p
+		case <-timer.C:
+			return TraceProbe{}
+		case <-ctx.Done():
+			return TraceProbe{}
+		}
+	}
+}
+
+func isUnreachable(t icmp.Type) bool {
+	return t == ipv4.ICMPTypeDestinationUnreachable || t == ipv6.ICMPTypeDestinationUnreachable
+}
+
+// reverseName returns the first PTR name of ip without its final dot, "" if it has none.
+func reverseName(ctx context.Context, ip net.IP) string {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip.String())
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// quotedPorts returns the source and destination ports of the udp or tcp header quoted by e.
+func quotedPorts(e *icmpError) (src, dst int) {
+	return int(binary.BigEndian.Uint16(e.Inner[0:2])), int(binary.BigEndian.Uint16(e.Inner[2:4]))
+}
+
+// traceICMP sends echo requests through the socket reading the answers.
+type traceICMP struct {
+	s   *icmpSocket
+	dst *net.IPAddr
+	id  int
+}
+
+func (t *traceICMP) send(ttl, seq int) (<-chan TraceProbe, error) {
+	if err := t.s.SetTTL(ttl); err != nil {
+		return nil, err
+	}
+	b, err := t.s.echoRequest(t.id, seq&0xffff, []byte("gonetlibs traceroute"))
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.s.WriteTo(b, t.dst)
+	return nil, err
+}
+
+func (t *traceICMP) match(m *traceMsg, seq int) bool {
+	if m.echo != nil {
+		return m.echo.ID == t.id && m.echo.Seq == seq&0xffff && m.peer.Equal(t.dst.IP)
+	}
+	requestType := byte(ipv4.ICMPTypeEcho)
+	proto := ProtocolICMP
+	if t.s.v6() {
+		requestType, proto = byte(ipv6.ICMPTypeEchoRequest), ProtocolIPv6ICMP
+	}
+	e := m.err
+	return e.Proto == proto && e.Inner[0] == requestType && e.Dst.Equal(t.dst.IP) &&
+		int(binary.BigEndian.Uint16(e.Inner[4:6])) == t.id && int(binary.BigEndian.Uint16(e.Inner[6:8])) == seq&0xffff
+}
+
+func (t *traceICMP) Close() error {
+	return nil
+}
+
+// traceUDP sends datagrams from one socket, each to the next port.
+type traceUDP struct {
+	conn  *net.UDPConn
+	p4    *ipv4.PacketConn
+	p6    *ipv6.PacketConn
+	dst   *net.IPAddr
+	lport int
+	port  int
+}
+
+func newTraceUDP(dst *net.IPAddr, laddr string, port int) (*traceUDP, error) {
+	network := "udp4"
+	if dst.IP.To4() == nil {
+		network = "udp6"
+	}
+	local := &net.UDPAddr{}
+	if len(laddr) != 0 {
+		ip, zone, _ := strings.Cut(laddr, "%")
+		local = &net.UDPAddr{IP: net.ParseIP(ip), Zone: zone}
+	}
+	conn, err := net.ListenUDP(network, local)
+	if err != nil {
+		return nil, err
+	}
+	t := &traceUDP{conn: conn, dst: dst, lport: conn.LocalAddr().(*net.UDPAddr).Port, port: port}
+	if network == "udp6" {
+		t.p6 = ipv6.NewPacketConn(conn)
+	} else {
+		t.p4 = ipv4.NewPacketConn(conn)
+	}
+	return t, nil
+}
+
+// portOf is the destination port of probe seq, traceroute style: 33434, 33435...
+func (t *traceUDP) portOf(seq int) int {
+	return (t.port+seq-2)%65535 + 1
+}
+
+func (t *traceUDP) send(ttl, seq int) (<-chan TraceProbe, error) {
+	var err error
+	if t.p6 != nil {
+		err = t.p6.SetHopLimit(ttl)
+	} else {
+		err = t.p4.SetTTL(ttl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.conn.WriteToUDP([]byte("gonetlibs traceroute"), &net.UDPAddr{IP: t.dst.IP, Port: t.portOf(seq), Zone: t.dst.Zone})
+	return nil, err
+}
+
+func (t *traceUDP) match(m *traceMsg, seq int) bool {
+	if m.err == nil || m.err.Proto != syscall.IPPROTO_UDP || !m.err.Dst.Equal(t.dst.IP) {
+		return false
+	}
+	src, dst := quotedPorts(m.err)
+	return src == t.lport && dst == t.portOf(seq)
+}
+
+func (t *traceUDP) Close() error {
+	return t.conn.Close()
+}
+
+// traceTCP opens a connection per probe, the kernel sends the SYN with the probe TTL.
+type traceTCP struct {
+	dst     *net.IPAddr
+	laddr   string
+	port    int
+	timeout time.Duration
+	lport   atomic.Int32 // source port of the probe in flight
+	cancel  context.CancelFunc
+}
+
+func (t *traceTCP) send(ttl, seq int) (<-chan TraceProbe, error) {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	var local *net.IPAddr
+	if len(t.laddr) != 0 {
+		ip, zone, _ := strings.Cut(t.laddr, "%")
+		local = &net.IPAddr{IP: net.ParseIP(ip), Zone: zone}
+	}
+	control, err := tcpTraceControl(ttl, local, &t.lport)
+	if err != nil {
+		return nil, err
+	}
+	var ctx context.Context
+	ctx, t.cancel = context.WithTimeout(context.Background(), t.timeout)
+	done := make(chan TraceProbe, 1)
+	go func() {
+		d := net.Dialer{Control: control}
+		start := time.Now()
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(t.dst.String(), strconv.Itoa(t.port)))
+		if err == nil {
+			conn.Close()
+		}
+		if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+			done <- TraceProbe{Addr: t.dst.IP, RTT: time.Since(start)}
+		}
+	}()
+	return done, nil
+}
+
+func (t *traceTCP) match(m *traceMsg, seq int) bool {
+	if m.err == nil || m.err.Proto != syscall.IPPROTO_TCP || !m.err.Dst.Equal(t.dst.IP) {
+		return false
+	}
+	src, dst := quotedPorts(m.err)
+	return src == int(t.lport.Load()) && dst == t.port
+}
+
+func (t *traceTCP) Close() error {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return nil
+}
+
+/*
+NetTraceConectionToServer checks the connection to domain like
+NetCheckConectionToServer. When it fails, the tcp path to the server port is
+traced through the same interface and returned with the error, LastHop tells
+where it breaks. The trace is nil when the connection works.
+*/
+func NetTraceConectionToServer(domain string, ifacenames ...string) (*TraceResult, error) {
+	ifacename := ""
+	if len(ifacenames) != 0 {
+		ifacename = ifacenames[0]
+	}
+	err := NetCheckConectionToServer(domain, ifacename)
+	if err == nil {
+		return nil, nil
+	}
+	host, port, perr := serverHostPort(domain)
+	if perr != nil {
+		return nil, err
+	}
+	nport, _ := strconv.Atoi(port)
+	trace, terr := Traceroute(context.Background(), host, &TraceOptions{Proto: TraceTCP, Iface: ifacename, Family: IPFamilyV4, Port: nport, Queries: 1})
+	if terr != nil {
+		return nil, err
+	}
+	return trace, err
+}
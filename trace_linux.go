@@ -0,0 +1,63 @@
+package gonetlibs
+
+import (
+	"net"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+tcpTraceControl returns a dialer control setting the TTL of the SYN. The socket
+is bound there rather than at connect, so the source port quoted by the ICMP
+errors is known: it is stored in lport.
+*/
+func tcpTraceControl(ttl int, laddr *net.IPAddr, lport *atomic.Int32) (func(network, address string, c syscall.RawConn) error, error) {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			var sa unix.Sockaddr
+			if network == "tcp6" {
+				sa6 := &unix.SockaddrInet6{}
+				if laddr != nil {
+					copy(sa6.Addr[:], laddr.IP.To16())
+					if ief, err := net.InterfaceByName(laddr.Zone); err == nil {
+						sa6.ZoneId = uint32(ief.Index)
+					}
+				}
+				if serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, ttl); serr != nil {
+					return
+				}
+				sa = sa6
+			} else {
+				sa4 := &unix.SockaddrInet4{}
+				if laddr != nil {
+					copy(sa4.Addr[:], laddr.IP.To4())
+				}
+				if serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL, ttl); serr != nil {
+					return
+				}
+				sa = sa4
+			}
+			if serr = unix.Bind(int(fd), sa); serr != nil {
+				return
+			}
+			bound, err := unix.Getsockname(int(fd))
+			if err != nil {
+				serr = err
+				return
+			}
+			switch a := bound.(type) {
+			case *unix.SockaddrInet4:
+				lport.Store(int32(a.Port))
+			case *unix.SockaddrInet6:
+				lport.Store(int32(a.Port))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package gonetlibs
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+)
+
+// tcpTraceControl is only implemented on Linux.
+func tcpTraceControl(ttl int, laddr *net.IPAddr, lport *atomic.Int32) (func(network, address string, c syscall.RawConn) error, error) {
+	return nil, fmt.Errorf("tcp traceroute is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,67 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestParseICMPErrorQuotedUDP(t *testing.T) {
+	// time exceeded quoting a udp datagram 10.0.0.1:40000 -> 192.0.2.9:33434
+	quoted := make([]byte, 28)
+	quoted[0], quoted[9] = 0x45, 17
+	copy(quoted[12:16], net.IPv4(10, 0, 0, 1).To4())
+	copy(quoted[16:20], net.IPv4(192, 0, 2, 9).To4())
+	quoted[20], quoted[21], quoted[22], quoted[23] = 0x9c, 0x40, 0x82, 0x9a
+	b, err := (&icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted}}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := parseICMPError(b, false)
+	if err != nil || e == nil {
+		t.Fatalf("parseICMPError = %v, %v", e, err)
+	}
+	src, dst := quotedPorts(e)
+	if e.Proto != 17 || !e.Dst.Equal(net.IPv4(192, 0, 2, 9)) || src != 40000 || dst != 33434 {
+		t.Errorf("parsed %+v, ports %d -> %d", e, src, dst)
+	}
+}
+
+func TestTraceHopString(t *testing.T) {
+	hop := TraceHop{TTL: 3, Probes: []TraceProbe{
+		{Addr: net.IPv4(192, 0, 2, 1), Name: "gw.example", RTT: 1500 * time.Microsecond},
+		{},
+		{Addr: net.IPv4(192, 0, 2, 1), RTT: 2 * time.Millisecond, Err: &UnreachableError{From: net.IPv4(192, 0, 2, 1), Code: 1}},
+	}}
+	if s, want := hop.String(), " 3  gw.example (192.0.2.1)  1.500 ms *  2.000 ms !H"; s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+	if addrs := hop.Addrs(); len(addrs) != 1 {
+		t.Errorf("Addrs() = %v", addrs)
+	}
+	r := &TraceResult{Hops: []TraceHop{hop, {TTL: 4, Probes: []TraceProbe{{}}}}}
+	if last := r.LastHop(); last == nil || last.TTL != 3 {
+		t.Errorf("LastHop() = %v", last)
+	}
+}
+
+func TestTracerouteLoopback(t *testing.T) {
+	for _, proto := range []TraceProto{TraceUDP, TraceICMP, TraceTCP} {
+		r, err := Traceroute(context.Background(), "127.0.0.1", &TraceOptions{Proto: proto, Queries: 1, MaxTTL: 3, Timeout: 500 * time.Millisecond})
+		if errors.Is(err, os.ErrPermission) {
+			t.Skip("no permission to open a raw ICMP socket")
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", proto, err)
+		}
+		if !r.Reached || len(r.Hops) != 1 {
+			t.Errorf("%s: %s", proto, r)
+		}
+	}
+}
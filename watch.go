@@ -0,0 +1,88 @@
+package gonetlibs
+
+import (
+	"fmt"
+	"net"
+)
+
+// IfaceEventType is the kind of change reported by NetWatchInterfaces.
+type IfaceEventType int
+
+const (
+	IfaceEventLinkAdded IfaceEventType = iota + 1
+	IfaceEventLinkRemoved
+	IfaceEventLinkUp
+	IfaceEventLinkDown
+	IfaceEventAddrAdded
+	IfaceEventAddrRemoved
+	IfaceEventMTUChanged
+	IfaceEventRenamed
+)
+
+func (t IfaceEventType) String() string {
+	switch t {
+	case IfaceEventLinkAdded:
+		return "link-added"
+	case IfaceEventLinkRemoved:
+		return "link-removed"
+	case IfaceEventLinkUp:
+		return "link-up"
+	case IfaceEventLinkDown:
+		return "link-down"
+	case IfaceEventAddrAdded:
+		return "addr-added"
+	case IfaceEventAddrRemoved:
+		return "addr-removed"
+	case IfaceEventMTUChanged:
+		return "mtu-changed"
+	case IfaceEventRenamed:
+		return "renamed"
+	}
+	return fmt.Sprintf("IfaceEventType(%d)", int(t))
+}
+
+// IfaceEvent is one interface or address change.
+type IfaceEvent struct {
+	Type    IfaceEventType
+	Iface   IfaceInfo  // interface state after the change
+	Addr    *IfaceAddr // address added or removed, nil for link events
+	OldName string     // previous name for IfaceEventRenamed
+	OldMTU  int        // previous MTU for IfaceEventMTUChanged
+}
+
+func (e IfaceEvent) String() string {
+	switch e.Type {
+	case IfaceEventAddrAdded, IfaceEventAddrRemoved:
+		return fmt.Sprintf("%s %s %s", e.Iface.Name, e.Type, e.Addr.CIDR())
+	case IfaceEventRenamed:
+		return fmt.Sprintf("%s %s from %s", e.Iface.Name, e.Type, e.OldName)
+	case IfaceEventMTUChanged:
+		return fmt.Sprintf("%s %s %d -> %d", e.Iface.Name, e.Type, e.OldMTU, e.Iface.MTU)
+	}
+	return fmt.Sprintf("%s %s", e.Iface.Name, e.Type)
+}
+
+// ifaceIsUp reports whether the link can carry traffic. Links without a
+// carrier notion (loopback, tun) report the "unknown" operstate.
+func ifaceIsUp(info IfaceInfo) bool {
+	return info.OperState == "up" || (info.OperState == "unknown" && info.Flags&net.FlagRunning != 0)
+}
+
+// diffIface returns the link events turning old into cur.
+func diffIface(old, cur IfaceInfo) []IfaceEvent {
+	var events []IfaceEvent
+	if old.Name != cur.Name {
+		events = append(events, IfaceEvent{Type: IfaceEventRenamed, Iface: cur, OldName: old.Name})
+	}
+	if old.MTU != cur.MTU {
+		events = append(events, IfaceEvent{Type: IfaceEventMTUChanged, Iface: cur, OldMTU: old.MTU})
+	}
+	if up := ifaceIsUp(cur); up != ifaceIsUp(old) {
+		if up {
+			events = append(events, IfaceEvent{Type: IfaceEventLinkUp, Iface: cur})
+		} else {
+			events = append(events, IfaceEvent{Type: IfaceEventLinkDown, Iface: cur})
+		}
+	}
+	return events
+}
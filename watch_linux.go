@@ -0,0 +1,180 @@
+package gonetlibs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// how often a blocked netlink read wakes up to check the context
+const watchPollInterval = 250 * time.Millisecond
+
+/*
+NetWatchInterfaces streams link and address changes from rtnetlink until ctx is
+cancelled, then the channel is closed.
+*/
+func NetWatchInterfaces(ctx context.Context) (<-chan IfaceEvent, error) {
+	c, err := nlOpen(unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR)
+	if err != nil {
+		return nil, err
+	}
+	tv := unix.NsecToTimeval(watchPollInterval.Nanoseconds())
+	if err = unix.SetsockoptTimeval(c.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		c.Close()
+		return nil, err
+	}
+	w := &ifaceWatcher{conn: c, events: make(chan IfaceEvent, 16)}
+	if err = w.resync(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	go w.run(ctx)
+	return w.events, nil
+}
+
+type ifaceWatcher struct {
+	conn   *nlConn
+	events chan IfaceEvent
+	links  map[int]IfaceInfo
+	addrs  map[int]map[string]IfaceAddr
+}
+
+func (w *ifaceWatcher) run(ctx context.Context) {
+	defer close(w.events)
+	defer w.conn.Close()
+	for ctx.Err() == nil {
+		msgs, err := w.conn.recv()
+		switch {
+		case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.ENOBUFS):
+			// the kernel dropped notifications, compare against a fresh dump
+			if w.resync(ctx) != nil {
+				return
+			}
+			continue
+		case err != nil:
+			return
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case unix.RTM_NEWLINK:
+				if info, ok := parseLinkMessage(m); ok {
+					w.updateLink(ctx, info)
+				}
+			case unix.RTM_DELLINK:
+				if info, ok := parseLinkMessage(m); ok {
+					delete(w.links, info.Index)
+					delete(w.addrs, info.Index)
+					w.emit(ctx, IfaceEvent{Type: IfaceEventLinkRemoved, Iface: info})
+				}
+			case unix.RTM_NEWADDR:
+				if index, a, ok := parseAddrMessage(m); ok {
+					w.updateAddr(ctx, index, a, true)
+				}
+			case unix.RTM_DELADDR:
+				if index, a, ok := parseAddrMessage(m); ok {
+					w.updateAddr(ctx, index, a, false)
+				}
+			}
+		}
+	}
+}
+
+// resync loads the current tables. The first call only records them, later
+// calls emit the differences found.
+func (w *ifaceWatcher) resync(ctx context.Context) error {
+	links, err := listIfaces()
+	if err != nil {
+		return err
+	}
+	addrs, err := listIfaceAddrs()
+	if err != nil {
+		return err
+	}
+	if w.links == nil {
+		w.links = make(map[int]IfaceInfo)
+		w.addrs = make(map[int]map[string]IfaceAddr)
+		for _, info := range links {
+			w.links[info.Index] = info
+			w.addrs[info.Index] = make(map[string]IfaceAddr)
+			for _, a := range addrs[info.Index] {
+				w.addrs[info.Index][a.CIDR()] = a
+			}
+		}
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	for _, info := range links {
+		seen[info.Index] = true
+		w.updateLink(ctx, info)
+		current := make(map[string]bool)
+		for _, a := range addrs[info.Index] {
+			current[a.CIDR()] = true
+			w.updateAddr(ctx, info.Index, a, true)
+		}
+		for key, a := range w.addrs[info.Index] {
+			if !current[key] {
+				w.updateAddr(ctx, info.Index, a, false)
+			}
+		}
+	}
+	for index, info := range w.links {
+		if !seen[index] {
+			delete(w.links, index)
+			delete(w.addrs, index)
+			w.emit(ctx, IfaceEvent{Type: IfaceEventLinkRemoved, Iface: info})
+		}
+	}
+	return nil
+}
+
+func (w *ifaceWatcher) updateLink(ctx context.Context, info IfaceInfo) {
+	old, known := w.links[info.Index]
+	w.links[info.Index] = info
+	if !known {
+		w.addrs[info.Index] = make(map[string]IfaceAddr)
+		w.emit(ctx, IfaceEvent{Type: IfaceEventLinkAdded, Iface: info})
+		if ifaceIsUp(info) {
+			w.emit(ctx, IfaceEvent{Type: IfaceEventLinkUp, Iface: info})
+		}
+		return
+	}
+	for _, e := range diffIface(old, info) {
+		w.emit(ctx, e)
+	}
+}
+
+func (w *ifaceWatcher) updateAddr(ctx context.Context, index int, a IfaceAddr, added bool) {
+	set, ok := w.addrs[index]
+	if !ok {
+		set = make(map[string]IfaceAddr)
+		w.addrs[index] = set
+	}
+	key := a.CIDR()
+	_, known := set[key]
+	if added {
+		set[key] = a
+	} else {
+		delete(set, key)
+	}
+	// the kernel repeats RTM_NEWADDR when IPv6 lifetimes are refreshed
+	if known == added {
+		return
+	}
+	e := IfaceEvent{Type: IfaceEventAddrAdded, Iface: w.links[index], Addr: &a}
+	if !added {
+		e.Type = IfaceEventAddrRemoved
+	}
+	w.emit(ctx, e)
+}
+
+func (w *ifaceWatcher) emit(ctx context.Context, e IfaceEvent) {
+	select {
+	case w.events <- e:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package gonetlibs
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// NetWatchInterfaces needs rtnetlink and is only available on Linux.
+func NetWatchInterfaces(ctx context.Context) (<-chan IfaceEvent, error) {
+	return nil, fmt.Errorf("NetWatchInterfaces is not supported on %s", runtime.GOOS)
+}
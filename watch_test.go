@@ -0,0 +1,34 @@
+package gonetlibs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDiffIface(t *testing.T) {
+	old := IfaceInfo{Index: 2, Name: "eth0", MTU: 1500, OperState: "down"}
+	cur := IfaceInfo{Index: 2, Name: "lan0", MTU: 1400, OperState: "up"}
+
+	events := diffIface(old, cur)
+	want := []IfaceEventType{IfaceEventRenamed, IfaceEventMTUChanged, IfaceEventLinkUp}
+	if len(events) != len(want) {
+		t.Fatalf("got %v, want %v", events, want)
+	}
+	for i, e := range events {
+		if e.Type != want[i] {
+			t.Errorf("event %d is %s, want %s", i, e.Type, want[i])
+		}
+	}
+	if events[0].OldName != "eth0" || events[1].OldMTU != 1500 {
+		t.Errorf("previous values not reported: %+v", events)
+	}
+
+	if events := diffIface(cur, cur); len(events) != 0 {
+		t.Errorf("no change reported %v", events)
+	}
+
+	tun := IfaceInfo{Name: "tun0", OperState: "unknown", Flags: net.FlagUp | net.FlagRunning}
+	if !ifaceIsUp(tun) {
+		t.Errorf("%s with unknown operstate and running flag should be up", tun.Name)
+	}
+}
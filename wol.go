@@ -0,0 +1,123 @@
+package gonetlibs
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// WolOptions tunes NetWakeOnLan. A nil *WolOptions sends a plain magic packet over UDP port 9.
+type WolOptions struct {
+	Password  string // SecureOn password, 4 bytes as a.b.c.d or 6 bytes as aa:bb:cc:dd:ee:ff
+	Raw       bool   // send an ethernet frame (ethertype 0x0842) instead of UDP, needs an interface and CAP_NET_RAW
+	Port      int    // UDP port, default 9
+	Broadcast string // UDP destination, default the broadcast address of the interface or 255.255.255.255
+}
+
+// ethertype of Wake-on-LAN frames sent without IP
+const etherTypeWol = 0x0842
+
+/*
+NetWakeOnLan wakes the host with hardware address mac by sending a magic packet,
+through the interface ifacenames[0] if given.
+*/
+func NetWakeOnLan(mac string, opts *WolOptions, ifacenames ...string) error {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return err
+	}
+	o := WolOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	var password []byte
+	if len(o.Password) != 0 {
+		if password, err = ParseWolPassword(o.Password); err != nil {
+			return err
+		}
+	}
+	packet, err := WolMagicPacket(hwaddr, password)
+	if err != nil {
+		return err
+	}
+	ifacename := ""
+	if len(ifacenames) != 0 {
+		ifacename = ifacenames[0]
+	}
+
+	if o.Raw {
+		if len(ifacename) == 0 {
+			return fmt.Errorf("raw Wake-on-LAN needs an interface")
+		}
+		return sendWolRaw(ifacename, packet)
+	}
+
+	port := o.Port
+	if port == 0 {
+		port = 9
+	}
+	dst := o.Broadcast
+	var local *net.UDPAddr
+	if len(ifacename) != 0 {
+		d, err := NetInterfaceDetails(ifacename)
+		if err != nil {
+			return err
+		}
+		for _, a := range d.Addrs {
+			if a.Family != 4 {
+				continue
+			}
+			local = &net.UDPAddr{IP: a.IP}
+			if len(dst) == 0 && a.Broadcast != nil {
+				dst = a.Broadcast.String()
+			}
+			break
+		}
+		if local == nil {
+			return fmt.Errorf("There isn't any ipv4 on interface %s", ifacename)
+		}
+	}
+	if len(dst) == 0 {
+		dst = net.IPv4bcast.String()
+	}
+	d := net.Dialer{}
+	if local != nil {
+		d.LocalAddr = local
+		d.Control = wolBindControl(ifacename)
+	}
+	conn, err := d.Dial("udp4", net.JoinHostPort(dst, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(packet)
+	return err
+}
+
+/*
+WolMagicPacket builds a magic packet: 6 bytes 0xff, the hardware address 16
+times, then the optional SecureOn password (4 or 6 bytes).
+*/
+func WolMagicPacket(mac net.HardwareAddr, password []byte) ([]byte, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("%s is not an ethernet address", mac)
+	}
+	if len(password) != 0 && len(password) != 4 && len(password) != 6 {
+		return nil, fmt.Errorf("SecureOn password must be 4 or 6 bytes")
+	}
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	packet = append(packet, bytes.Repeat(mac, 16)...)
+	return append(packet, password...), nil
+}
+
+// ParseWolPassword parses a SecureOn password written as a.b.c.d or aa:bb:cc:dd:ee:ff.
+func ParseWolPassword(s string) ([]byte, error) {
+	if ip := net.ParseIP(s).To4(); ip != nil {
+		return []byte(ip), nil
+	}
+	if hw, err := net.ParseMAC(s); err == nil && len(hw) == 6 {
+		return []byte(hw), nil
+	}
+	return nil, fmt.Errorf("invalid SecureOn password %q", s)
+}
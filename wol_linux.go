@@ -0,0 +1,56 @@
+package gonetlibs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+wolBindControl binds the UDP socket to ifacename, a broadcast would otherwise
+leave through the default route. Before linux 5.7 this needs CAP_NET_RAW, without
+it the socket only keeps the bind to the address of ifacename.
+*/
+func wolBindControl(ifacename string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = unix.BindToDevice(int(fd), ifacename)
+		})
+		if err != nil {
+			return err
+		}
+		if serr == unix.EPERM {
+			return nil
+		}
+		return serr
+	}
+}
+
+// sendWolRaw broadcasts the magic packet as an ethernet frame on ifacename.
+func sendWolRaw(ifacename string, packet []byte) error {
+	info, err := NetGetIface(ifacename)
+	if err != nil {
+		return err
+	}
+	if len(info.MAC) != 6 {
+		return fmt.Errorf("interface %s has no ethernet address", ifacename)
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	frame := make([]byte, 14, 14+len(packet))
+	copy(frame[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], info.MAC)
+	binary.BigEndian.PutUint16(frame[12:14], etherTypeWol)
+	frame = append(frame, packet...)
+
+	to := &unix.SockaddrLinklayer{Protocol: htons(etherTypeWol), Ifindex: info.Index, Halen: 6}
+	copy(to.Addr[:], frame[0:6])
+	return unix.Sendto(fd, frame, 0, to)
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package gonetlibs
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// wolBindControl has no SO_BINDTODEVICE to use, the source address alone picks the interface.
+func wolBindControl(ifacename string) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
+
+// sendWolRaw needs AF_PACKET sockets and is only available on Linux.
+func sendWolRaw(ifacename string, packet []byte) error {
+	return fmt.Errorf("raw Wake-on-LAN is not supported on %s", runtime.GOOS)
+}
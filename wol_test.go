@@ -0,0 +1,59 @@
+package gonetlibs
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWolMagicPacket(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	password, err := ParseWolPassword("192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet, err := WolMagicPacket(mac, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet) != 6+16*6+4 {
+		t.Fatalf("packet is %d bytes", len(packet))
+	}
+	if !bytes.Equal(packet[:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("bad sync stream % x", packet[:6])
+	}
+	for i := 0; i < 16; i++ {
+		if !bytes.Equal(packet[6+6*i:12+6*i], mac) {
+			t.Errorf("repetition %d is % x", i, packet[6+6*i:12+6*i])
+		}
+	}
+	if !bytes.Equal(packet[102:], []byte{192, 168, 1, 1}) {
+		t.Errorf("bad password % x", packet[102:])
+	}
+	if _, err := ParseWolPassword("secret"); err == nil {
+		t.Error("invalid password accepted")
+	}
+}
+
+func TestNetWakeOnLan(t *testing.T) {
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+
+	if err := NetWakeOnLan("00:11:22:33:44:55", &WolOptions{Broadcast: "127.0.0.1", Port: port, Password: "01:02:03:04:05:06"}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 256)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 108 || !bytes.Equal(buf[102:n], []byte{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("received % x", buf[:n])
+	}
+}